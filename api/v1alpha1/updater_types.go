@@ -27,11 +27,119 @@ import (
 type UpdaterSpec struct {
 	Registry   Registry   `json:"registry,omitempty"`
 	Repository Repository `json:"repository,omitempty"`
+
+	// Image is the image reference as written in the manifests, e.g.
+	// `mirror.example.com/acme/app` while the tags are polled from
+	// `index.docker.io/acme/app`. It defaults to the registry repository.
+	Image string `json:"image,omitempty"`
 }
 
 type Registry struct {
-	DockerHub string `json:"dockerHub,omitempty"`
-	Filter    string `json:"filter,omitempty"`
+	DockerHub string   `json:"dockerHub,omitempty"`
+	OCI       *OCI     `json:"oci,omitempty"`
+	Helm      *Helm    `json:"helm,omitempty"`
+	GitTags   *GitTags `json:"gitTags,omitempty"`
+	Filter    string   `json:"filter,omitempty"`
+
+	// Include lists regular expressions of which a tag has to match one.
+	Include []string `json:"include,omitempty"`
+	// Exclude lists regular expressions of which a tag must match none.
+	Exclude []string `json:"exclude,omitempty"`
+	// Extract is a template such as `$build` expanded with the capture
+	// groups of the filter matching a tag. The policy orders the tags by
+	// the expanded value instead of the tag itself.
+	Extract string `json:"extract,omitempty"`
+
+	// Policy decides how the latest tag is chosen among the listed tags,
+	// by default semver for Helm charts and alphabetical otherwise.
+	// +kubebuilder:validation:Enum=alphabetical;numerical;semver;created
+	Policy string `json:"policy,omitempty"`
+	// Range restricts the semver policy to versions satisfying the
+	// constraint, e.g. `~1.4` or `>=2.0 <3`.
+	Range string `json:"range,omitempty"`
+	// Prerelease lets the semver policy choose pre-release versions.
+	Prerelease bool `json:"prerelease,omitempty"`
+
+	// MinAge holds back tags younger than the duration, e.g. `30m`.
+	MinAge *metav1.Duration `json:"minAge,omitempty"`
+	// AgeSource decides how the age of a tag is measured: `firstSeen`
+	// from the first time the operator listed the tag, `created` from
+	// the creation time in the image config.
+	// +kubebuilder:validation:Enum=firstSeen;created
+	AgeSource string `json:"ageSource,omitempty"`
+
+	// Pin decides how the chosen tag is written into manifests: `tag`
+	// writes `image:tag`, `digest` writes `image@sha256:...` and
+	// `tagDigest` writes `image:tag@sha256:...`.
+	// +kubebuilder:validation:Enum=tag;digest;tagDigest
+	Pin string `json:"pin,omitempty"`
+
+	// Platforms only lets tags through whose image is available for each
+	// of the platforms, e.g. `linux/amd64` or `linux/arm/v7`.
+	Platforms []string `json:"platforms,omitempty"`
+
+	// Verify only lets tags with a valid cosign signature through.
+	Verify *Verification `json:"verify,omitempty"`
+
+	// PullSecret is the name of a kubernetes.io/dockerconfigjson Secret
+	// in the namespace of the Updater holding the registry credentials.
+	PullSecret string `json:"pullSecret,omitempty"`
+	// ServiceAccountName is the name of a ServiceAccount in the namespace
+	// of the Updater whose imagePullSecrets hold the registry credentials.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+}
+
+// OCI is a repository hosted on a registry implementing the OCI
+// distribution API, such as Harbor, Artifactory or registry:2.
+type OCI struct {
+	// URL is the repository url, e.g. `harbor.example.com/project/app`.
+	URL string `json:"url"`
+	// Insecure talks to the registry over plain HTTP.
+	Insecure bool `json:"insecure,omitempty"`
+	// CABundle is a PEM encoded CA bundle trusted in addition to the
+	// system roots.
+	CABundle string `json:"caBundle,omitempty"`
+}
+
+// Helm is a Helm chart whose versions are tracked instead of image tags.
+// The chart version is written to the `version` or `targetRevision` next
+// to the references of the chart, and to the matching dependencies of
+// Chart.yaml files.
+type Helm struct {
+	// URL is the chart repository url, either serving an index.yaml such
+	// as `https://charts.bitnami.com/bitnami`, or an OCI registry such as
+	// `oci://ghcr.io/stefanprodan/charts`.
+	URL string `json:"url"`
+	// Chart is the name of the chart in the repository.
+	Chart string `json:"chart"`
+	// Insecure talks to the repository over plain HTTP.
+	Insecure bool `json:"insecure,omitempty"`
+	// CABundle is a PEM encoded CA bundle trusted in addition to the
+	// system roots.
+	CABundle string `json:"caBundle,omitempty"`
+}
+
+// GitTags is a git repository whose tags, or GitHub releases, are tracked
+// instead of image tags. The tag is written to the release download, blob
+// and tree urls of the repository, and to kustomize remote bases pinned
+// with `?ref=`.
+type GitTags struct {
+	// URL is the git repository url, e.g. `https://github.com/owner/repo`.
+	URL string `json:"url"`
+	// Releases lists the GitHub releases of the repository instead of its
+	// tags. Drafts are skipped, and pre-releases unless `prerelease` is
+	// set.
+	Releases bool `json:"releases,omitempty"`
+}
+
+// Verification describes how the cosign signature of a tag is verified.
+type Verification struct {
+	// PublicKeySecret is the name of a Secret in the namespace of the
+	// Updater holding the cosign public key under `cosign.pub`.
+	PublicKeySecret string `json:"publicKeySecret"`
+	// BuilderID additionally requires an in-toto provenance attestation
+	// signed by the same key and naming this builder id.
+	BuilderID string `json:"builderID,omitempty"`
 }
 
 type Repository struct {
@@ -39,6 +147,41 @@ type Repository struct {
 	Base string `json:"base,omitempty"`
 	Head string `json:"head,omitempty"`
 	Path string `json:"path,omitempty"`
+
+	// Mode decides how updates land on Base: `pullRequest` pushes them to
+	// Head and opens a pull request, `push` commits them to Base directly.
+	// +kubebuilder:validation:Enum=pullRequest;push
+	Mode string `json:"mode,omitempty"`
+
+	// Provider is the service hosting Git, where pull requests are opened.
+	// +kubebuilder:validation:Enum=github;gitlab;gitea;bitbucketServer;azureDevOps
+	Provider string `json:"provider,omitempty"`
+	// APIURL is the API endpoint of a self-managed provider, when it is not
+	// served from the default location on the host of Git, e.g.
+	// `https://example.com/gitlab/api/v4`.
+	APIURL string `json:"apiURL,omitempty"`
+	// CredentialsSecret is the name of a Secret in the namespace of the
+	// Updater holding the `token`, and optionally the `username`, used to
+	// push to Git and open pull requests. Required by the providers other
+	// than github, which falls back to the credentials of the operator.
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+
+	// Strategy decides how the files below Path are rewritten: `image`
+	// rewrites the image fields of workload manifests, `kustomize` the
+	// images transformer of the kustomizations, `helmValues` the image
+	// tags of Helm values files and `setters` the fields marked with a
+	// `# {"$imagepolicy": "<namespace>:<name>"}` comment naming the Updater.
+	// +kubebuilder:validation:Enum=image;kustomize;helmValues;setters
+	Strategy string `json:"strategy,omitempty"`
+
+	// ValuesFiles are glob patterns of the values files rewritten by the
+	// helmValues strategy, e.g. `values-prd.yaml`. Patterns without a
+	// slash match the file name in any directory below Path.
+	ValuesFiles []string `json:"valuesFiles,omitempty"`
+	// ValuesKeys are dotted key paths set to the tag by the helmValues
+	// strategy, e.g. `backend.image.tag`, in addition to the `tag` next
+	// to each `repository` naming the image.
+	ValuesKeys []string `json:"valuesKeys,omitempty"`
 }
 
 // UpdaterStatus defines the observed state of Updater
@@ -18,14 +18,19 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/authn"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	manifestupdaterkoyutaiov1alpha1 "manifest-updater/api/v1alpha1"
+	"manifest-updater/pkg/registry"
 	"manifest-updater/updater"
 )
 
@@ -33,6 +38,15 @@ const (
 	defaultBranch = "master"
 )
 
+const (
+	cosignPublicKey = "cosign.pub"
+)
+
+const (
+	credentialsUsername = "username"
+	credentialsToken    = "token"
+)
+
 const (
 	imageTagRegexp = `( *)(?P<tag>\w[\w-\.]{0,127})`
 )
@@ -47,6 +61,7 @@ type UpdaterReconciler struct {
 
 // +kubebuilder:rbac:groups=manifest-updater.koyuta.io.koyuta.io,resources=updaters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=manifest-updater.koyuta.io.koyuta.io,resources=updaters/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets;serviceaccounts,verbs=get;list;watch
 
 func (r *UpdaterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -74,14 +89,82 @@ func (r *UpdaterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 	}
 	entry := &updater.Entry{
-		ID:        string(u.ObjectMeta.UID),
-		Deleted:   !u.ObjectMeta.DeletionTimestamp.IsZero(),
-		DockerHub: u.Spec.Registry.DockerHub,
-		Filter:    u.Spec.Registry.Filter,
-		Git:       u.Spec.Repository.Git,
-		Base:      u.Spec.Repository.Base,
-		Head:      u.Spec.Repository.Head,
-		Path:      u.Spec.Repository.Path,
+		ID:          string(u.ObjectMeta.UID),
+		Namespace:   u.Namespace,
+		Name:        u.Name,
+		Deleted:     !u.ObjectMeta.DeletionTimestamp.IsZero(),
+		DockerHub:   u.Spec.Registry.DockerHub,
+		Filter:      u.Spec.Registry.Filter,
+		Include:     u.Spec.Registry.Include,
+		Exclude:     u.Spec.Registry.Exclude,
+		Extract:     u.Spec.Registry.Extract,
+		Policy:      u.Spec.Registry.Policy,
+		Range:       u.Spec.Registry.Range,
+		Prerelease:  u.Spec.Registry.Prerelease,
+		Platforms:   u.Spec.Registry.Platforms,
+		Pin:         u.Spec.Registry.Pin,
+		Image:       u.Spec.Image,
+		Git:         u.Spec.Repository.Git,
+		Base:        u.Spec.Repository.Base,
+		Head:        u.Spec.Repository.Head,
+		Path:        u.Spec.Repository.Path,
+		Mode:        u.Spec.Repository.Mode,
+		Strategy:    u.Spec.Repository.Strategy,
+		Provider:    u.Spec.Repository.Provider,
+		APIURL:      u.Spec.Repository.APIURL,
+		ValuesFiles: u.Spec.Repository.ValuesFiles,
+		ValuesKeys:  u.Spec.Repository.ValuesKeys,
+	}
+	if !entry.Deleted {
+		keychain, err := r.resolveKeychain(ctx, u)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		entry.Keychain = keychain
+
+		if v := u.Spec.Registry.Verify; v != nil {
+			secret := &corev1.Secret{}
+			key := types.NamespacedName{Namespace: u.Namespace, Name: v.PublicKeySecret}
+			if err := r.Get(ctx, key, secret); err != nil {
+				return ctrl.Result{}, err
+			}
+			// A Secret lacking the key must not turn the verification off.
+			if len(secret.Data[cosignPublicKey]) == 0 {
+				return ctrl.Result{}, fmt.Errorf("secret %s has no %s key", v.PublicKeySecret, cosignPublicKey)
+			}
+			entry.Verify = true
+			entry.CosignPublicKey = secret.Data[cosignPublicKey]
+			entry.BuilderID = v.BuilderID
+		}
+
+		if name := u.Spec.Repository.CredentialsSecret; name != "" {
+			secret := &corev1.Secret{}
+			key := types.NamespacedName{Namespace: u.Namespace, Name: name}
+			if err := r.Get(ctx, key, secret); err != nil {
+				return ctrl.Result{}, err
+			}
+			entry.GitUser = string(secret.Data[credentialsUsername])
+			entry.GitToken = string(secret.Data[credentialsToken])
+		}
+	}
+	if minAge := u.Spec.Registry.MinAge; minAge != nil {
+		entry.MinAge = minAge.Duration
+		entry.AgeSource = u.Spec.Registry.AgeSource
+	}
+	if oci := u.Spec.Registry.OCI; oci != nil {
+		entry.OCI = oci.URL
+		entry.Insecure = oci.Insecure
+		entry.CABundle = oci.CABundle
+	}
+	if helm := u.Spec.Registry.Helm; helm != nil {
+		entry.HelmURL = helm.URL
+		entry.HelmChart = helm.Chart
+		entry.Insecure = helm.Insecure
+		entry.CABundle = helm.CABundle
+	}
+	if tags := u.Spec.Registry.GitTags; tags != nil {
+		entry.GitTags = tags.URL
+		entry.Releases = tags.Releases
 	}
 	r.Queue <- entry
 
@@ -94,6 +177,47 @@ func (r *UpdaterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
+// resolveKeychain builds the registry keychain from the pull secret and
+// the imagePullSecrets of the service account referenced by the Updater.
+func (r *UpdaterReconciler) resolveKeychain(ctx context.Context, u *manifestupdaterkoyutaiov1alpha1.Updater) (authn.Keychain, error) {
+	var names []string
+	if u.Spec.Registry.PullSecret != "" {
+		names = append(names, u.Spec.Registry.PullSecret)
+	}
+	if u.Spec.Registry.ServiceAccountName != "" {
+		sa := &corev1.ServiceAccount{}
+		key := types.NamespacedName{Namespace: u.Namespace, Name: u.Spec.Registry.ServiceAccountName}
+		if err := r.Get(ctx, key, sa); err != nil {
+			return nil, err
+		}
+		for _, s := range sa.ImagePullSecrets {
+			names = append(names, s.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var configs [][]byte
+	for _, name := range names {
+		secret := &corev1.Secret{}
+		key := types.NamespacedName{Namespace: u.Namespace, Name: name}
+		if err := r.Get(ctx, key, secret); err != nil {
+			return nil, err
+		}
+		switch secret.Type {
+		case corev1.SecretTypeDockerConfigJson:
+			configs = append(configs, secret.Data[corev1.DockerConfigJsonKey])
+		case corev1.SecretTypeDockercfg:
+			config := append([]byte(`{"auths":`), secret.Data[corev1.DockerConfigKey]...)
+			configs = append(configs, append(config, '}'))
+		default:
+			return nil, fmt.Errorf("secret %s has unsupported type %s", name, secret.Type)
+		}
+	}
+	return registry.NewDockerConfigKeychain(configs...)
+}
+
 func containsString(slice []string, s string) bool {
 	for _, item := range slice {
 		if item == s {
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	manifestupdaterkoyutaiov1alpha1 "manifest-updater/api/v1alpha1"
+	"manifest-updater/updater"
+)
+
+func TestResolveKeychain(t *testing.T) {
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: "default", Name: name}
+	}
+	r := &UpdaterReconciler{Client: fake.NewFakeClient(
+		&corev1.Secret{
+			ObjectMeta: meta("pull"),
+			Type:       corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(`{"auths":{"https://index.docker.io/v1/":{"username":"alice","password":"secret"}}}`),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: meta("legacy"),
+			Type:       corev1.SecretTypeDockercfg,
+			Data: map[string][]byte{
+				corev1.DockerConfigKey: []byte(`{"docker.io":{"username":"bob","password":"secret"},"quay.io":{"username":"carol","password":"secret"}}`),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: meta("opaque"),
+			Type:       corev1.SecretTypeOpaque,
+		},
+		&corev1.ServiceAccount{
+			ObjectMeta:       meta("puller"),
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "legacy"}},
+		},
+		&corev1.ServiceAccount{
+			ObjectMeta:       meta("broken"),
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "opaque"}},
+		},
+	)}
+	updater := func(registry manifestupdaterkoyutaiov1alpha1.Registry) *manifestupdaterkoyutaiov1alpha1.Updater {
+		return &manifestupdaterkoyutaiov1alpha1.Updater{
+			ObjectMeta: meta("app"),
+			Spec:       manifestupdaterkoyutaiov1alpha1.UpdaterSpec{Registry: registry},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		registry manifestupdaterkoyutaiov1alpha1.Registry
+		want     map[string]string
+	}{
+		{
+			name:     "pull secret",
+			registry: manifestupdaterkoyutaiov1alpha1.Registry{PullSecret: "pull"},
+			want:     map[string]string{"acme/app": "alice", "quay.io/acme/app": ""},
+		},
+		{
+			name:     "legacy dockercfg",
+			registry: manifestupdaterkoyutaiov1alpha1.Registry{ServiceAccountName: "puller"},
+			want:     map[string]string{"acme/app": "bob", "quay.io/acme/app": "carol"},
+		},
+		{
+			name:     "pull secret takes precedence",
+			registry: manifestupdaterkoyutaiov1alpha1.Registry{PullSecret: "pull", ServiceAccountName: "puller"},
+			want:     map[string]string{"acme/app": "alice", "quay.io/acme/app": "carol"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keychain, err := r.resolveKeychain(context.Background(), updater(tt.registry))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for repo, want := range tt.want {
+				ref, err := name.NewRepository(repo)
+				if err != nil {
+					t.Fatal(err)
+				}
+				auth, err := keychain.Resolve(ref)
+				if err != nil {
+					t.Fatal(err)
+				}
+				config, err := auth.Authorization()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if config.Username != want {
+					t.Errorf("%s: want %q, got %q", repo, want, config.Username)
+				}
+			}
+		})
+	}
+
+	keychain, err := r.resolveKeychain(context.Background(), updater(manifestupdaterkoyutaiov1alpha1.Registry{}))
+	if err != nil || keychain != nil {
+		t.Errorf("want no keychain, got %v and %v", keychain, err)
+	}
+	if _, err := r.resolveKeychain(context.Background(), updater(manifestupdaterkoyutaiov1alpha1.Registry{ServiceAccountName: "broken"})); err == nil {
+		t.Error("want an error for an opaque secret")
+	}
+	if _, err := r.resolveKeychain(context.Background(), updater(manifestupdaterkoyutaiov1alpha1.Registry{PullSecret: "missing"})); err == nil {
+		t.Error("want an error for a missing secret")
+	}
+}
+
+func TestReconcileVerifyPublicKey(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := manifestupdaterkoyutaiov1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		data   map[string][]byte
+		verify bool
+	}{
+		{name: "public key", data: map[string][]byte{cosignPublicKey: []byte("key")}, verify: true},
+		{name: "missing key", data: map[string][]byte{"cosign.key": []byte("key")}},
+		{name: "empty key", data: map[string][]byte{cosignPublicKey: {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := make(chan *updater.Entry, 1)
+			r := &UpdaterReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme,
+					&corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cosign"},
+						Data:       tt.data,
+					},
+					&manifestupdaterkoyutaiov1alpha1.Updater{
+						ObjectMeta: metav1.ObjectMeta{
+							Namespace:  "default",
+							Name:       "app",
+							Finalizers: []string{"finalizer.manifest-updater.koyuta.io"},
+						},
+						Spec: manifestupdaterkoyutaiov1alpha1.UpdaterSpec{
+							Registry: manifestupdaterkoyutaiov1alpha1.Registry{
+								DockerHub: "acme/app",
+								Verify:    &manifestupdaterkoyutaiov1alpha1.Verification{PublicKeySecret: "cosign"},
+							},
+						},
+					},
+				),
+				Queue: queue,
+			}
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "app"}}
+			_, err := r.Reconcile(req)
+			if !tt.verify {
+				if err == nil || len(queue) != 0 {
+					t.Errorf("want an error and no entry, got %v and %d entries", err, len(queue))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry := <-queue; !entry.Verify || string(entry.CosignPublicKey) != "key" {
+				t.Errorf("want the entry verified with the key, got %v and %q", entry.Verify, entry.CosignPublicKey)
+			}
+		})
+	}
+}
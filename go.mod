@@ -3,18 +3,27 @@ module manifest-updater
 go 1.13
 
 require (
+	github.com/Masterminds/semver/v3 v3.1.0
 	github.com/go-git/go-git/v5 v5.0.0
-	github.com/go-logr/logr v0.1.0
-	github.com/google/go-cmp v0.3.1 // indirect
-	github.com/google/go-containerregistry v0.0.0-20200310013544-4fe717a9b4cb
+	github.com/go-logr/logr v0.2.0
+	github.com/google/go-containerregistry v0.5.1
 	github.com/google/go-github v17.0.0+incompatible
 	github.com/google/go-querystring v1.0.0 // indirect
-	github.com/onsi/ginkgo v1.11.0
-	github.com/onsi/gomega v1.8.1
-	golang.org/x/crypto v0.0.0-20200311171314-f7b00557c8c4 // indirect
-	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
-	golang.org/x/sync v0.0.0-20190423024810-112230192c58
+	github.com/onsi/ginkgo v1.12.0
+	github.com/onsi/gomega v1.9.0
+	github.com/prometheus/client_golang v1.0.0
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
+	golang.org/x/sync v0.0.0-20201207232520-09787c993a3a
+	gopkg.in/yaml.v3 v3.0.1
+	k8s.io/api v0.17.2
 	k8s.io/apimachinery v0.17.2
 	k8s.io/client-go v0.17.2
 	sigs.k8s.io/controller-runtime v0.5.0
 )
+
+// go-containerregistry pulls versions of logr and kube-openapi that are
+// incompatible with controller-runtime v0.5 and client-go v0.17.
+replace (
+	github.com/go-logr/logr => github.com/go-logr/logr v0.1.0
+	k8s.io/kube-openapi => k8s.io/kube-openapi v0.0.0-20191107075043-30be4d16710a
+)
@@ -19,6 +19,7 @@ package main
 import (
 	"context"
 	"flag"
+	"io/ioutil"
 	"os"
 	"time"
 
@@ -31,6 +32,8 @@ import (
 
 	manifestupdaterkoyutaiov1alpha1 "manifest-updater/api/v1alpha1"
 	"manifest-updater/controllers"
+	"manifest-updater/pkg/repository"
+	"manifest-updater/pkg/webhook"
 	"manifest-updater/updater"
 	// +kubebuilder:scaffold:imports
 )
@@ -53,15 +56,30 @@ func main() {
 		interval    uint
 		user        string
 		token       string
+
+		appID         int64
+		appPrivateKey string
+
+		webhookAddr   string
+		webhookSecret string
 	)
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.UintVar(&interval, "interval", 60, "")
 	flag.StringVar(&user, "user", "", "")
 	flag.StringVar(&token, "token", "", "")
+	flag.Int64Var(&appID, "github-app-id", 0, "The id of the GitHub App to authenticate to GitHub as, instead of --user and --token.")
+	flag.StringVar(&appPrivateKey, "github-app-private-key", "", "The path of the PEM encoded private key of the GitHub App.")
+	flag.StringVar(&webhookAddr, "webhook-addr", "", "The address the registry webhook receiver binds to. Disabled when empty.")
+	flag.StringVar(&webhookSecret, "webhook-secret", "", "The shared secret registry webhooks have to present. Required with --webhook-addr.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
+	if webhookAddr != "" && webhookSecret == "" {
+		setupLog.Error(webhook.ErrNoSecret, "unable to start webhook receiver")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -74,6 +92,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	var app *repository.GitHubApp
+	if appID != 0 {
+		key, err := ioutil.ReadFile(appPrivateKey)
+		if err != nil {
+			setupLog.Error(err, "unable to read GitHub App private key")
+			os.Exit(1)
+		}
+		if app, err = repository.NewGitHubApp(appID, key); err != nil {
+			setupLog.Error(err, "unable to load GitHub App private key")
+			os.Exit(1)
+		}
+	}
+
 	queue := make(chan *updater.Entry, 1)
 
 	if err = (&controllers.UpdaterReconciler{
@@ -93,16 +124,25 @@ func main() {
 		ctrl.Log.WithName("Loop"),
 		user,
 		token,
+		app,
 	)
 
+	receiver := &webhook.Receiver{
+		Secret:   webhookSecret,
+		Notifier: looper,
+		Logger:   ctrl.Log.WithName("Webhook"),
+	}
+
 	var (
-		loopStop = make(chan struct{}, 1)
-		mgrStop  = make(chan struct{}, 1)
+		loopStop    = make(chan struct{}, 1)
+		mgrStop     = make(chan struct{}, 1)
+		webhookStop = make(chan struct{}, 1)
 	)
 	go func() {
 		<-ctrl.SetupSignalHandler()
 		loopStop <- struct{}{}
 		mgrStop <- struct{}{}
+		webhookStop <- struct{}{}
 	}()
 
 	var eg, _ = errgroup.WithContext(context.Background())
@@ -110,6 +150,12 @@ func main() {
 	eg.Go(func() error {
 		return looper.Loop(loopStop)
 	})
+	if webhookAddr != "" {
+		setupLog.Info("starting webhook receiver")
+		eg.Go(func() error {
+			return receiver.ListenAndServe(webhookAddr, webhookStop)
+		})
+	}
 	setupLog.Info("starting manager")
 	eg.Go(func() error {
 		return mgr.Start(mgrStop)
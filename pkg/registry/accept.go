@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+var (
+	ErrChecksUnsupported = errors.New("Platform, signature and creation time checks need an image registry")
+)
+
+// Rejection tells why a tag was held back although the policy ranked it
+// higher than the tag that was chosen.
+type Rejection struct {
+	Tag    string `json:"tag"`
+	Reason string `json:"reason"`
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("tag %s was rejected: %s", r.Tag, r.Reason)
+}
+
+// Rejections returns the tags held back by the latest lookup.
+func (f *TagFilter) Rejections() []Rejection {
+	if f == nil {
+		return nil
+	}
+	return f.rejected
+}
+
+// check returns a Rejection when tag must not be proposed. The digests whose
+// platforms or signature were checked are recorded in checked.
+func (f *TagFilter) check(ctx context.Context, repo name.Repository, opts []remote.Option, tag string, seen map[v1.Hash]time.Time, checked map[v1.Hash]bool) (*Rejection, error) {
+	old, err := f.oldEnough(ctx, repo, opts, tag, seen)
+	if err != nil {
+		return nil, err
+	}
+	if !old {
+		return &Rejection{Tag: tag, Reason: fmt.Sprintf("younger than %s", f.MinAge)}, nil
+	}
+	if f == nil || (f.Verifier == nil && len(f.Platforms) == 0) {
+		return nil, nil
+	}
+
+	// Both checks are cached by digest, so resolving it is enough for the
+	// tags checked before.
+	desc, err := remote.Head(repo.Tag(tag), withContext(ctx, opts)...)
+	if err != nil {
+		return nil, err
+	}
+	checked[desc.Digest] = true
+	if len(f.Platforms) > 0 {
+		missing, err := f.missingPlatforms(ctx, repo, opts, desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			return &Rejection{Tag: tag, Reason: fmt.Sprintf("missing platforms %s", strings.Join(missing, ", "))}, nil
+		}
+	}
+	if f.Verifier != nil {
+		if err := f.Verifier.Verify(ctx, repo, opts, desc.Digest); err != nil {
+			if !isRejected(err) {
+				return nil, err
+			}
+			return &Rejection{Tag: tag, Reason: err.Error()}, nil
+		}
+	}
+	return nil, nil
+}
+
+// retrieveSeenTag retrieves the latest tag among the tags first listed at
+// least MinAge ago. It serves the sources other than image registries,
+// whose tags have no image to check the age, platforms or signature of.
+func retrieveSeenTag(ctx context.Context, filter *TagFilter, policy TagPolicy, tags []string) (string, error) {
+	if filter.checksImages() {
+		return "", ErrChecksUnsupported
+	}
+	return retrieveAcceptedTag(ctx, name.Repository{}, nil, filter, policy, tags)
+}
+
+// checksImages reports whether the filter has to inspect the images of the
+// tags.
+func (f *TagFilter) checksImages() bool {
+	if f == nil {
+		return false
+	}
+	return f.Verifier != nil || len(f.Platforms) > 0 || (f.MinAge > 0 && f.AgeSource == AgeSourceCreated)
+}
+
+// retrieveAcceptedTag retrieves the latest tag among the tags passing the
+// checks of filter, such as the minimum age.
+func retrieveAcceptedTag(ctx context.Context, repo name.Repository, opts []remote.Option, filter *TagFilter, policy TagPolicy, tags []string) (string, error) {
+	filter.observe(tags)
+
+	var rejected []Rejection
+	if filter != nil {
+		defer func() { filter.rejected = rejected }()
+	}
+
+	seen := map[v1.Hash]time.Time{}
+	if filter != nil && filter.AgeSource == AgeSourceCreated {
+		// Only keep the creation times of the tags checked this time,
+		// which are most likely the ones checked next time.
+		defer filter.age.times.replace(seen)
+	}
+	checked := map[v1.Hash]bool{}
+	if filter != nil && len(filter.Platforms) > 0 {
+		// Likewise for the platforms and the verdicts of the signatures.
+		defer filter.platforms.keep(checked)
+	}
+	if filter != nil && filter.Verifier != nil {
+		defer filter.Verifier.keep(checked)
+	}
+	for {
+		tag, err := retrieveLatestTag(filter, policy, tags)
+		if err != nil {
+			return "", err
+		}
+		rejection, err := filter.check(ctx, repo, opts, tag, seen, checked)
+		if err != nil {
+			return "", err
+		}
+		if rejection == nil {
+			return tag, nil
+		}
+		rejected = append(rejected, *rejection)
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		candidates := make([]string, 0, len(tags)-1)
+		for _, t := range tags {
+			if t != tag {
+				candidates = append(candidates, t)
+			}
+		}
+		tags = candidates
+	}
+}
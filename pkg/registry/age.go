@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+const (
+	// AgeSourceCreated measures the age of a tag from the `created` field
+	// of its image config.
+	AgeSourceCreated = "created"
+	// AgeSourceFirstSeen measures the age of a tag from the first time the
+	// registry listed it to the operator.
+	AgeSourceFirstSeen = "firstSeen"
+)
+
+var (
+	ErrUnknownAgeSource = errors.New("Unknown tag age source")
+)
+
+// ageGate holds back tags younger than the minimum age of a TagFilter.
+type ageGate struct {
+	times creationTimes
+}
+
+// FirstSeen records the first time each tag was listed. It outlives the
+// TagFilter, which is rebuilt whenever its Updater is reconciled.
+type FirstSeen struct {
+	mu sync.Mutex
+	m  map[string]time.Time
+}
+
+func NewFirstSeen() *FirstSeen {
+	return &FirstSeen{m: map[string]time.Time{}}
+}
+
+// SetMinAge makes the filter hold back tags younger than minAge, measured
+// according to source.
+func (f *TagFilter) SetMinAge(minAge time.Duration, source string) error {
+	switch source {
+	case "", AgeSourceFirstSeen:
+		source = AgeSourceFirstSeen
+	case AgeSourceCreated:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownAgeSource, source)
+	}
+	f.MinAge = minAge
+	f.AgeSource = source
+	return nil
+}
+
+// observe records the first time each of tags was listed, and forgets
+// the tags that are gone.
+func (f *TagFilter) observe(tags []string) {
+	if f == nil || f.MinAge == 0 {
+		return
+	}
+	if f.FirstSeen == nil {
+		f.FirstSeen = NewFirstSeen()
+	}
+	f.FirstSeen.observe(tags)
+}
+
+func (s *FirstSeen) observe(tags []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := nowFunc()
+	m := make(map[string]time.Time, len(tags))
+	for _, t := range tags {
+		if seen, ok := s.m[t]; ok {
+			m[t] = seen
+		} else {
+			m[t] = now
+		}
+	}
+	s.m = m
+}
+
+func (s *FirstSeen) get(tag string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m[tag]
+}
+
+// oldEnough reports whether tag is at least MinAge old. The creation times
+// looked up are recorded in seen.
+func (f *TagFilter) oldEnough(ctx context.Context, repo name.Repository, opts []remote.Option, tag string, seen map[v1.Hash]time.Time) (bool, error) {
+	if f == nil || f.MinAge == 0 {
+		return true, nil
+	}
+
+	var since time.Time
+	switch f.AgeSource {
+	case AgeSourceCreated:
+		digest, created, err := f.age.times.get(ctx, repo.Tag(tag), opts)
+		if err != nil {
+			return false, err
+		}
+		seen[digest] = created
+		since = created
+	default:
+		since = f.FirstSeen.get(tag)
+	}
+	return !nowFunc().Before(since.Add(f.MinAge)), nil
+}
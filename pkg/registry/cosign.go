@@ -0,0 +1,314 @@
+package registry
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+const (
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	dsseMediaType             = "application/vnd.dsse.envelope.v1+json"
+	intotoPayloadType         = "application/vnd.in-toto+json"
+)
+
+var (
+	ErrInvalidPublicKey     = errors.New("Invalid public key")
+	ErrSignatureNotFound    = errors.New("No valid signature found")
+	ErrAttestationNotFound  = errors.New("No valid provenance attestation found")
+	ErrUnsupportedPublicKey = errors.New("Unsupported public key type")
+)
+
+// CosignVerifier verifies the cosign signature, and optionally the in-toto
+// provenance attestation, stored next to an image in its repository.
+type CosignVerifier struct {
+	// BuilderID is the builder id the provenance attestation has to name.
+	// Attestations are not checked when it is empty.
+	BuilderID string `json:"builderID,omitempty"`
+
+	key crypto.PublicKey
+
+	mu       sync.Mutex
+	verdicts map[v1.Hash]verdict
+}
+
+// verdict is the outcome of the verification of a digest.
+type verdict struct {
+	err error
+	at  time.Time
+}
+
+// rejectionTTL is how long a digest without a valid signature stays
+// rejected before it is verified again, so that signatures pushed after
+// the image are picked up eventually.
+var rejectionTTL = time.Hour
+
+// NewCosignVerifier returns a CosignVerifier trusting the PEM encoded
+// public key, as written by `cosign generate-key-pair`.
+func NewCosignVerifier(publicKey []byte, builderID string) (*CosignVerifier, error) {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+	default:
+		return nil, ErrUnsupportedPublicKey
+	}
+	return &CosignVerifier{
+		BuilderID: builderID,
+		key:       key,
+		verdicts:  map[v1.Hash]verdict{},
+	}, nil
+}
+
+// Verify verifies the image digest in repo. It fails with
+// ErrSignatureNotFound or ErrAttestationNotFound when the digest lacks a
+// valid signature or attestation, and with any other error when they
+// could not be read. Verified digests are remembered, since signatures
+// are immutable once pushed, and rejected ones for rejectionTTL.
+func (c *CosignVerifier) Verify(ctx context.Context, repo name.Repository, opts []remote.Option, digest v1.Hash) error {
+	c.mu.Lock()
+	v, ok := c.verdicts[digest]
+	c.mu.Unlock()
+	if ok && (v.err == nil || nowFunc().Before(v.at.Add(rejectionTTL))) {
+		return v.err
+	}
+
+	opts = withContext(ctx, opts)
+	err := c.verifySignature(repo, opts, digest)
+	if err == nil && c.BuilderID != "" {
+		err = c.verifyAttestation(repo, opts, digest)
+	}
+	if err != nil && !isRejected(err) {
+		return err
+	}
+
+	c.mu.Lock()
+	c.verdicts[digest] = verdict{err: err, at: nowFunc()}
+	c.mu.Unlock()
+	return err
+}
+
+// keep forgets the verdicts of the digests not in digests.
+func (c *CosignVerifier) keep(digests map[v1.Hash]bool) {
+	c.mu.Lock()
+	for d := range c.verdicts {
+		if !digests[d] {
+			delete(c.verdicts, d)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// isRejected reports whether err tells that an image lacks a valid
+// signature or attestation, rather than that they could not be read.
+func isRejected(err error) bool {
+	return errors.Is(err, ErrSignatureNotFound) || errors.Is(err, ErrAttestationNotFound)
+}
+
+type simpleSigning struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+func (c *CosignVerifier) verifySignature(repo name.Repository, opts []remote.Option, digest v1.Hash) error {
+	img, err := remote.Image(repo.Tag(cosignTag(digest, "sig")), opts...)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("%w: %v", ErrSignatureNotFound, err)
+		}
+		return err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+	for _, layer := range manifest.Layers {
+		sig, err := base64.StdEncoding.DecodeString(layer.Annotations[cosignSignatureAnnotation])
+		if err != nil || len(sig) == 0 {
+			continue
+		}
+		payload, err := readBlob(img, layer.Digest)
+		if err != nil {
+			return err
+		}
+		if c.verifyBytes(payload, sig) != nil {
+			continue
+		}
+		var s simpleSigning
+		if err := json.Unmarshal(payload, &s); err != nil {
+			continue
+		}
+		if s.Critical.Image.DockerManifestDigest == digest.String() {
+			return nil
+		}
+	}
+	return ErrSignatureNotFound
+}
+
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+	Signatures  []struct {
+		Sig string `json:"sig"`
+	} `json:"signatures"`
+}
+
+type intotoStatement struct {
+	Subject []struct {
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	Predicate struct {
+		// SLSA provenance v0.2
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		// SLSA provenance v1
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+		} `json:"runDetails"`
+	} `json:"predicate"`
+}
+
+func (c *CosignVerifier) verifyAttestation(repo name.Repository, opts []remote.Option, digest v1.Hash) error {
+	img, err := remote.Image(repo.Tag(cosignTag(digest, "att")), opts...)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("%w: %v", ErrAttestationNotFound, err)
+		}
+		return err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != dsseMediaType {
+			continue
+		}
+		blob, err := readBlob(img, layer.Digest)
+		if err != nil {
+			return err
+		}
+		var envelope dsseEnvelope
+		if err := json.Unmarshal(blob, &envelope); err != nil || envelope.PayloadType != intotoPayloadType {
+			continue
+		}
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			continue
+		}
+		if !c.verifyEnvelope(envelope, payload) {
+			continue
+		}
+		var statement intotoStatement
+		if err := json.Unmarshal(payload, &statement); err != nil {
+			continue
+		}
+		if !statement.hasSubject(digest) {
+			continue
+		}
+		builder := statement.Predicate.Builder.ID
+		if builder == "" {
+			builder = statement.Predicate.RunDetails.Builder.ID
+		}
+		if builder == c.BuilderID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: builder %s", ErrAttestationNotFound, c.BuilderID)
+}
+
+func (c *CosignVerifier) verifyEnvelope(envelope dsseEnvelope, payload []byte) bool {
+	// https://github.com/secure-systems-lab/dsse/blob/master/protocol.md
+	pae := []byte(fmt.Sprintf("DSSEv1 %d %s %d ", len(envelope.PayloadType), envelope.PayloadType, len(payload)))
+	pae = append(pae, payload...)
+	for _, s := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		if c.verifyBytes(pae, sig) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *intotoStatement) hasSubject(digest v1.Hash) bool {
+	for _, subject := range s.Subject {
+		if subject.Digest[digest.Algorithm] == digest.Hex {
+			return true
+		}
+	}
+	return false
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+func (c *CosignVerifier) verifyBytes(message, sig []byte) error {
+	h := sha256.Sum256(message)
+	switch key := c.key.(type) {
+	case *ecdsa.PublicKey:
+		var s ecdsaSignature
+		if _, err := asn1.Unmarshal(sig, &s); err != nil {
+			return err
+		}
+		if !ecdsa.Verify(key, h[:], s.R, s.S) {
+			return ErrSignatureNotFound
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], sig)
+	}
+	return ErrUnsupportedPublicKey
+}
+
+// cosignTag returns the tag cosign stores the signatures (`sig`) or the
+// attestations (`att`) of digest under.
+func cosignTag(digest v1.Hash, suffix string) string {
+	return strings.Join([]string{digest.Algorithm, digest.Hex}, "-") + "." + suffix
+}
+
+func readBlob(img v1.Image, digest v1.Hash) ([]byte, error) {
+	layer, err := img.LayerByDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
@@ -0,0 +1,254 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// rawLayer is a layer stored as is, the way cosign stores payloads.
+type rawLayer struct {
+	content   []byte
+	mediaType types.MediaType
+}
+
+func (l *rawLayer) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(l.content))
+	return h, err
+}
+func (l *rawLayer) DiffID() (v1.Hash, error)            { return l.Digest() }
+func (l *rawLayer) Compressed() (io.ReadCloser, error)  { return l.Uncompressed() }
+func (l *rawLayer) Size() (int64, error)                { return int64(len(l.content)), nil }
+func (l *rawLayer) MediaType() (types.MediaType, error) { return l.mediaType, nil }
+func (l *rawLayer) Uncompressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l.content)), nil
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, message []byte) string {
+	t.Helper()
+	h := sha256.Sum256(message)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func pushSignature(t *testing.T, repo name.Repository, digest v1.Hash, key *ecdsa.PrivateKey) {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(
+		`{"critical":{"identity":{"docker-reference":"%s"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"}}`,
+		repo.Name(), digest,
+	))
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       &rawLayer{content: payload, mediaType: "application/vnd.dev.cosign.simplesigning.v1+json"},
+		Annotations: map[string]string{cosignSignatureAnnotation: sign(t, key, payload)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(repo.Tag(cosignTag(digest, "sig")), img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pushAttestation(t *testing.T, repo name.Repository, digest v1.Hash, key *ecdsa.PrivateKey, builderID string) {
+	t.Helper()
+	statement := []byte(fmt.Sprintf(
+		`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://slsa.dev/provenance/v0.2","subject":[{"name":"%s","digest":{"sha256":"%s"}}],"predicate":{"builder":{"id":"%s"}}}`,
+		repo.Name(), digest.Hex, builderID,
+	))
+	pae := []byte(fmt.Sprintf("DSSEv1 %d %s %d ", len(intotoPayloadType), intotoPayloadType, len(statement)))
+	pae = append(pae, statement...)
+	envelope := []byte(fmt.Sprintf(
+		`{"payloadType":"%s","payload":"%s","signatures":[{"sig":"%s"}]}`,
+		intotoPayloadType, base64.StdEncoding.EncodeToString(statement), sign(t, key, pae),
+	))
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer: &rawLayer{content: envelope, mediaType: dsseMediaType},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(repo.Tag(cosignTag(digest, "att")), img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCosignVerifier(t *testing.T) {
+	server := httptest.NewServer(newTagLister())
+	defer server.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	push := func() v1.Hash {
+		img, _ := random.Image(64, 1)
+		digest, _ := img.Digest()
+		if err := remote.Write(repo.Tag(digest.Hex[:8]), img); err != nil {
+			t.Fatal(err)
+		}
+		return digest
+	}
+
+	unsigned := push()
+	signed := push()
+	pushSignature(t, repo, signed, key)
+	forged := push()
+	pushSignature(t, repo, forged, other)
+	attested := push()
+	pushSignature(t, repo, attested, key)
+	pushAttestation(t, repo, attested, key, "https://ci.example.com/builder")
+
+	tests := []struct {
+		name      string
+		builderID string
+		digest    v1.Hash
+		err       error
+	}{
+		{name: "unsigned", digest: unsigned, err: ErrSignatureNotFound},
+		{name: "signed", digest: signed},
+		{name: "signed by another key", digest: forged, err: ErrSignatureNotFound},
+		{name: "without attestation", builderID: "https://ci.example.com/builder", digest: signed, err: ErrAttestationNotFound},
+		{name: "attested", builderID: "https://ci.example.com/builder", digest: attested},
+		{name: "attested by another builder", builderID: "https://evil.example.com", digest: attested, err: ErrAttestationNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewCosignVerifier(publicKey, tt.builderID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := v.Verify(context.Background(), repo, nil, tt.digest); !errors.Is(err, tt.err) {
+				t.Errorf("want %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestCosignVerifierCache(t *testing.T) {
+	defer func(f func() time.Time) { nowFunc = f }(nowFunc)
+	now := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	nowFunc = func() time.Time { return now }
+
+	var failing bool
+	counter := &requestCounter{handler: newTagLister(), counts: map[string]int{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing && strings.HasSuffix(r.URL.Path, ".sig") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		counter.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	v, err := NewCosignVerifier(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _ := random.Image(64, 1)
+	digest, _ := img.Digest()
+	if err := remote.Write(repo.Tag("1.0.0"), img); err != nil {
+		t.Fatal(err)
+	}
+
+	// A registry failure is no verdict.
+	failing = true
+	if err := v.Verify(context.Background(), repo, nil, digest); err == nil || isRejected(err) {
+		t.Fatalf("want a registry error, got %v", err)
+	}
+	failing = false
+
+	if err := v.Verify(context.Background(), repo, nil, digest); !errors.Is(err, ErrSignatureNotFound) {
+		t.Fatalf("want %v, got %v", ErrSignatureNotFound, err)
+	}
+	counter.reset()
+	if err := v.Verify(context.Background(), repo, nil, digest); !errors.Is(err, ErrSignatureNotFound) {
+		t.Fatalf("want %v, got %v", ErrSignatureNotFound, err)
+	}
+	if n := counter.count("GET manifests"); n != 0 {
+		t.Errorf("want the rejection cached, got %d manifest requests", n)
+	}
+
+	// Signatures pushed later are picked up once the rejection expired.
+	pushSignature(t, repo, digest, key)
+	now = now.Add(rejectionTTL)
+	if err := v.Verify(context.Background(), repo, nil, digest); err != nil {
+		t.Errorf("want the signature verified, got %v", err)
+	}
+}
+
+func TestRetrieveAcceptedTagVerdictCache(t *testing.T) {
+	server := httptest.NewServer(newTagLister())
+	defer server.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushTags(t, repo.String(), "1.0.0", "1.1.0")
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	v, err := NewCosignVerifier(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewTagFilter(nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Verifier = v
+
+	// The verdicts of tags no longer listed are forgotten.
+	for _, tt := range []struct {
+		tags []string
+		want int
+	}{
+		{tags: []string{"1.0.0", "1.1.0"}, want: 2},
+		{tags: []string{"1.1.0"}, want: 1},
+	} {
+		if _, err := retrieveAcceptedTag(context.Background(), repo, nil, f, &SemverPolicy{}, tt.tags); err != ErrNoTagsFound {
+			t.Fatalf("want %v, got %v", ErrNoTagsFound, err)
+		}
+		if n := len(v.verdicts); n != tt.want {
+			t.Errorf("%v: want %d verdicts, got %d", tt.tags, tt.want, n)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	PolicyCreated = "created"
+)
+
+var (
+	ErrPolicyNeedsRegistry = errors.New("Tag policy needs access to the registry")
+)
+
+const defaultCreatedConcurrency = 4
+
+// CreatedPolicy treats the tag whose image was created last, according to
+// the `created` field of its config, as the latest one. Creation times are
+// cached by manifest digest between calls, so only images that were pushed
+// since the previous call have their config fetched.
+type CreatedPolicy struct {
+	// Concurrency bounds the number of images inspected at once.
+	Concurrency int64 `json:"concurrency,omitempty"`
+
+	times creationTimes
+}
+
+func NewCreatedPolicy() *CreatedPolicy {
+	return &CreatedPolicy{Concurrency: defaultCreatedConcurrency}
+}
+
+// Latest always fails, since the images have to be inspected. Registries
+// bind the policy to the repository they list the tags of instead.
+func (c *CreatedPolicy) Latest(tags []string) (string, error) {
+	return "", ErrPolicyNeedsRegistry
+}
+
+func (c *CreatedPolicy) bind(ctx context.Context, repo name.Repository, opts []remote.Option) TagPolicy {
+	return &boundCreatedPolicy{CreatedPolicy: c, ctx: ctx, repo: repo, opts: opts}
+}
+
+type boundCreatedPolicy struct {
+	*CreatedPolicy
+
+	ctx  context.Context
+	repo name.Repository
+	opts []remote.Option
+}
+
+func (b *boundCreatedPolicy) Latest(tags []string) (string, error) {
+	if len(tags) == 0 {
+		return "", ErrNoTagsFound
+	}
+
+	concurrency := b.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultCreatedConcurrency
+	}
+
+	var (
+		created = make([]time.Time, len(tags))
+		seen    = map[v1.Hash]time.Time{}
+		mu      = sync.Mutex{}
+		sem     = semaphore.NewWeighted(concurrency)
+	)
+	eg, ctx := errgroup.WithContext(b.ctx)
+	for i := range tags {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			eg.Wait()
+			return "", err
+		}
+		i := i
+		eg.Go(func() error {
+			defer sem.Release(1)
+
+			digest, t, err := b.times.get(ctx, b.repo.Tag(tags[i]), b.opts)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			seen[digest] = t
+			mu.Unlock()
+			created[i] = t
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return "", err
+	}
+
+	// Forget the images whose tags are gone.
+	b.times.replace(seen)
+
+	var latest int
+	for i := range tags {
+		if created[i].After(created[latest]) ||
+			(created[i].Equal(created[latest]) && tags[i] > tags[latest]) {
+			latest = i
+		}
+	}
+	return tags[latest], nil
+}
+
+// creationTimes caches the creation time of images by manifest digest.
+type creationTimes struct {
+	mu sync.Mutex
+	m  map[v1.Hash]time.Time
+}
+
+// get returns the manifest digest and creation time of the image ref
+// points to. The digest is resolved with a HEAD request, which registries
+// such as Docker Hub do not count as a pull, and the manifest and config
+// are only fetched if the digest is not cached yet.
+func (c *creationTimes) get(ctx context.Context, ref name.Reference, opts []remote.Option) (v1.Hash, time.Time, error) {
+	opts = withContext(ctx, opts)
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		return v1.Hash{}, time.Time{}, err
+	}
+
+	c.mu.Lock()
+	t, ok := c.m[desc.Digest]
+	c.mu.Unlock()
+	if ok {
+		return desc.Digest, t, nil
+	}
+
+	img, err := remote.Image(ref.Context().Digest(desc.Digest.String()), opts...)
+	if err != nil {
+		return v1.Hash{}, time.Time{}, err
+	}
+	config, err := img.ConfigFile()
+	if err != nil {
+		return v1.Hash{}, time.Time{}, err
+	}
+	return desc.Digest, config.Created.Time, nil
+}
+
+// replace swaps the cache for m.
+func (c *creationTimes) replace(m map[v1.Hash]time.Time) {
+	c.mu.Lock()
+	c.m = m
+	c.mu.Unlock()
+}
@@ -0,0 +1,202 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// requestCounter counts the requests made to the registry by method and
+// kind of resource, and the manifest HEAD requests served at once.
+type requestCounter struct {
+	handler http.Handler
+	delay   time.Duration
+
+	mu       sync.Mutex
+	counts   map[string]int
+	inflight int
+	max      int
+}
+
+func (c *requestCounter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kind := "other"
+	switch {
+	case strings.Contains(r.URL.Path, "/manifests/"):
+		kind = "manifests"
+	case strings.Contains(r.URL.Path, "/blobs/"):
+		kind = "blobs"
+	}
+	c.mu.Lock()
+	c.counts[r.Method+" "+kind]++
+	c.mu.Unlock()
+
+	if r.Method == http.MethodHead && kind == "manifests" {
+		c.mu.Lock()
+		c.inflight++
+		if c.inflight > c.max {
+			c.max = c.inflight
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-time.After(c.delay):
+		case <-r.Context().Done():
+		}
+
+		c.mu.Lock()
+		c.inflight--
+		c.mu.Unlock()
+	}
+	c.handler.ServeHTTP(w, r)
+}
+
+func (c *requestCounter) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = map[string]int{}
+	c.max = 0
+}
+
+func (c *requestCounter) count(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[key]
+}
+
+func pushCreated(t *testing.T, repo, tag string, created time.Time) {
+	t.Helper()
+	ref, err := name.NewTag(repo + ":" + tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = mutate.CreatedAt(img, v1.Time{Time: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreatedPolicy(t *testing.T) {
+	counter := &requestCounter{handler: newTagLister(), delay: 20 * time.Millisecond, counts: map[string]int{}}
+	server := httptest.NewServer(counter)
+	defer server.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	tags := []string{"a", "b", "c", "d", "e", "f"}
+	for i, tag := range tags {
+		// "d" is the newest image although it is not the greatest tag.
+		created := base.Add(time.Duration(i) * time.Hour)
+		if tag == "d" {
+			created = base.Add(24 * time.Hour)
+		}
+		pushCreated(t, repo.String(), tag, created)
+	}
+
+	policy := NewCreatedPolicy()
+	policy.Concurrency = 2
+	latest := func() string {
+		t.Helper()
+		tag, err := policy.bind(context.Background(), repo, nil).Latest(tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tag
+	}
+
+	counter.reset()
+	if got := latest(); got != "d" {
+		t.Errorf("want %q, got %q", "d", got)
+	}
+	if counter.max > 2 {
+		t.Errorf("want at most 2 images inspected at once, got %d", counter.max)
+	}
+	if counter.count("GET manifests") != len(tags) || counter.count("GET blobs") != len(tags) {
+		t.Errorf("want the manifest and config of each image fetched once, got %v", counter.counts)
+	}
+
+	// The second lookup only resolves the digests.
+	counter.reset()
+	if got := latest(); got != "d" {
+		t.Errorf("want %q, got %q", "d", got)
+	}
+	if counter.count("HEAD manifests") != len(tags) || counter.count("GET manifests") != 0 || counter.count("GET blobs") != 0 {
+		t.Errorf("want the cached creation times reused, got %v", counter.counts)
+	}
+
+	// A tag moved to a newer image is inspected again.
+	pushCreated(t, repo.String(), "a", base.Add(48*time.Hour))
+	counter.reset()
+	if got := latest(); got != "a" {
+		t.Errorf("want %q, got %q", "a", got)
+	}
+	if counter.count("GET manifests") != 1 {
+		t.Errorf("want only the moved tag fetched, got %v", counter.counts)
+	}
+}
+
+func TestCreatedPolicyCancel(t *testing.T) {
+	counter := &requestCounter{handler: newTagLister(), counts: map[string]int{}}
+	server := httptest.NewServer(counter)
+	defer server.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := []string{"a", "b", "c"}
+	for _, tag := range tags {
+		pushCreated(t, repo.String(), tag, time.Now())
+	}
+	counter.delay = 5 * time.Second
+
+	f, err := NewTagFilter(nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetPlatforms([]string{"linux/amd64"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, fetch := range map[string]func(ctx context.Context) error{
+		"created policy": func(ctx context.Context) error {
+			_, err := NewCreatedPolicy().bind(ctx, repo, nil).Latest(tags)
+			return err
+		},
+		"tag checks": func(ctx context.Context) error {
+			_, err := listLatestTag(ctx, repo, f, &AlphabeticalPolicy{}, nil)
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			if err := fetch(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("want %v, got %v", context.DeadlineExceeded, err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("want the requests cancelled along with the context, took %s", elapsed)
+			}
+		})
+	}
+}
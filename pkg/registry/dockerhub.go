@@ -2,19 +2,24 @@ package registry
 
 import (
 	"context"
-	"regexp"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
 type DockerHubRegistry struct {
-	URL    string `json:"url"`
-	Filter string `json:"filter,omitempty"`
+	URL    string     `json:"url"`
+	Filter *TagFilter `json:"filter,omitempty"`
+	Policy TagPolicy  `json:"policy,omitempty"`
+
+	Keychain authn.Keychain `json:"-"`
 }
 
-func NewDockerHubRegistry(u, f string) *DockerHubRegistry {
-	return &DockerHubRegistry{URL: u, Filter: f}
+func NewDockerHubRegistry(u string, f *TagFilter, p TagPolicy, k authn.Keychain) *DockerHubRegistry {
+	if p == nil {
+		p = &AlphabeticalPolicy{}
+	}
+	return &DockerHubRegistry{URL: u, Filter: f, Policy: p, Keychain: k}
 }
 
 func (d *DockerHubRegistry) FetchLatestTag(ctx context.Context) (string, error) {
@@ -22,27 +27,17 @@ func (d *DockerHubRegistry) FetchLatestTag(ctx context.Context) (string, error)
 	if err != nil {
 		return "", err
 	}
-	tags, err := remote.ListWithContext(ctx, registry)
-	if len(tags) == 0 {
-		return "", ErrNoTagsFound
-	}
+	return listLatestTag(ctx, registry, d.Filter, d.Policy, remoteOptions(d.Keychain, nil))
+}
 
-	return retrieveLatestTag(d.Filter, tags)
+func (d *DockerHubRegistry) Rejections() []Rejection {
+	return d.Filter.Rejections()
 }
 
-func retrieveLatestTag(filter string, tags []string) (string, error) {
-	var tag = tags[len(tags)-1]
-	if filter != "" {
-		re, err := regexp.Compile(regexp.QuoteMeta(filter))
-		if err != nil {
-			return "", err
-		}
-		for _, t := range tags {
-			if re.MatchString(t) {
-				tag = t
-				break
-			}
-		}
+func (d *DockerHubRegistry) ResolveDigest(ctx context.Context, tag string) (string, error) {
+	registry, err := name.NewRepository(d.URL)
+	if err != nil {
+		return "", err
 	}
-	return tag, nil
+	return resolveDigest(ctx, registry.Tag(tag), remoteOptions(d.Keychain, nil))
 }
@@ -0,0 +1,120 @@
+package registry
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+var (
+	ErrExtractUnsupported = errors.New("Tag policy does not support extracted values")
+)
+
+// cosignTagRegexp matches the tags cosign stores signatures, attestations
+// and SBOMs under, which are never candidates.
+var cosignTagRegexp = regexp.MustCompile(`^sha256-[a-f0-9]{64}\.(sig|att|sbom)$`)
+
+// TagFilter selects the candidate tags among the listed tags, and the
+// values they are ordered by.
+type TagFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// Extract is a template such as `$build` expanded with the capture
+	// groups of the include pattern matching a tag. The expanded value is
+	// handed to the TagPolicy instead of the tag itself.
+	Extract string `json:"extract,omitempty"`
+	// MinAge holds back tags younger than the duration, measured
+	// according to AgeSource. See SetMinAge.
+	MinAge    time.Duration `json:"minAge,omitempty"`
+	AgeSource string        `json:"ageSource,omitempty"`
+	// FirstSeen keeps the first time tags were listed across the filters
+	// of the same Updater. A fresh one is used when it is nil.
+	FirstSeen *FirstSeen `json:"-"`
+	// Platforms holds back tags not available for each of the platforms.
+	// See SetPlatforms.
+	Platforms []v1.Platform `json:"platforms,omitempty"`
+	// Verifier holds back tags without a valid signature.
+	Verifier *CosignVerifier `json:"verifier,omitempty"`
+
+	include   []*regexp.Regexp
+	exclude   []*regexp.Regexp
+	age       ageGate
+	platforms platformCache
+	rejected  []Rejection
+}
+
+func NewTagFilter(include, exclude []string, extract string) (*TagFilter, error) {
+	f := &TagFilter{Include: include, Exclude: exclude, Extract: extract}
+	for _, p := range include {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, re)
+	}
+	for _, p := range exclude {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, re)
+	}
+	return f, nil
+}
+
+// Match reports whether tag matches any include pattern and no exclude
+// pattern, and returns the value the tag is ordered by.
+func (f *TagFilter) Match(tag string) (string, bool) {
+	if cosignTagRegexp.MatchString(tag) {
+		return "", false
+	}
+	if f == nil {
+		return tag, true
+	}
+	for _, re := range f.exclude {
+		if re.MatchString(tag) {
+			return "", false
+		}
+	}
+	if len(f.include) == 0 {
+		return tag, true
+	}
+	for _, re := range f.include {
+		match := re.FindStringSubmatchIndex(tag)
+		if match == nil {
+			continue
+		}
+		if f.Extract == "" {
+			return tag, true
+		}
+		return string(re.ExpandString(nil, f.Extract, tag, match)), true
+	}
+	return "", false
+}
+
+func retrieveLatestTag(filter *TagFilter, policy TagPolicy, tags []string) (string, error) {
+	var (
+		values     = map[string]string{}
+		candidates = []string{}
+	)
+	for _, t := range tags {
+		v, ok := filter.Match(t)
+		if !ok {
+			continue
+		}
+		prev, exists := values[v]
+		if !exists {
+			candidates = append(candidates, v)
+		}
+		if !exists || t > prev {
+			values[v] = t
+		}
+	}
+	latest, err := policy.Latest(candidates)
+	if err != nil {
+		return "", err
+	}
+	return values[latest], nil
+}
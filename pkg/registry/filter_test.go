@@ -0,0 +1,134 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestRetrieveLatestTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		extract string
+		policy  TagPolicy
+		tags    []string
+		want    string
+	}{
+		{
+			name:    "regexp filter",
+			include: []string{`^dev-.*`},
+			policy:  &AlphabeticalPolicy{},
+			tags:    []string{"dev-a", "dev-b", "prd-c"},
+			want:    "dev-b",
+		},
+		{
+			name:    "extracted build number",
+			include: []string{`^main-(?P<build>\d+)-[a-f0-9]{7}$`},
+			extract: "$build",
+			policy:  &NumericalPolicy{},
+			tags:    []string{"main-9-ffffff0", "main-10-0000000", "main-x-1234567"},
+			want:    "main-10-0000000",
+		},
+		{
+			name:    "extracted semver",
+			include: []string{`^app-v(?P<version>.*)$`},
+			exclude: []string{`-debug$`},
+			extract: "${version}",
+			policy:  &SemverPolicy{},
+			tags:    []string{"app-v1.9.0", "app-v1.10.0", "app-v1.11.0-debug"},
+			want:    "app-v1.10.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewTagFilter(tt.include, tt.exclude, tt.extract)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := retrieveLatestTag(f, tt.policy, tt.tags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRetrieveAcceptedTag(t *testing.T) {
+	defer func(f func() time.Time) { nowFunc = f }(nowFunc)
+	now := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	nowFunc = func() time.Time { return now }
+
+	f, err := NewTagFilter(nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetMinAge(10*time.Minute, AgeSourceFirstSeen); err != nil {
+		t.Fatal(err)
+	}
+	policy := &SemverPolicy{}
+	repo, _ := name.NewRepository("example/app")
+
+	if _, err := retrieveAcceptedTag(context.Background(), repo, nil, f, policy, []string{"1.0.0"}); err != ErrNoTagsFound {
+		t.Fatalf("want %v, got %v", ErrNoTagsFound, err)
+	}
+
+	now = now.Add(10 * time.Minute)
+	tag, err := retrieveAcceptedTag(context.Background(), repo, nil, f, policy, []string{"1.0.0", "1.1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "1.0.0" {
+		t.Errorf("want %q, got %q", "1.0.0", tag)
+	}
+
+	now = now.Add(10 * time.Minute)
+	tag, err = retrieveAcceptedTag(context.Background(), repo, nil, f, policy, []string{"1.0.0", "1.1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "1.1.0" {
+		t.Errorf("want %q, got %q", "1.1.0", tag)
+	}
+}
+
+func TestRetrieveAcceptedTagRebuiltFilter(t *testing.T) {
+	defer func(f func() time.Time) { nowFunc = f }(nowFunc)
+	now := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	nowFunc = func() time.Time { return now }
+
+	seen := NewFirstSeen()
+	repo, _ := name.NewRepository("example/app")
+	poll := func() (string, error) {
+		// Every reconcile of the Updater builds a new filter.
+		f, err := NewTagFilter(nil, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.SetMinAge(10*time.Hour, AgeSourceFirstSeen); err != nil {
+			t.Fatal(err)
+		}
+		f.FirstSeen = seen
+		return retrieveAcceptedTag(context.Background(), repo, nil, f, &SemverPolicy{}, []string{"1.0.0"})
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := poll(); err != ErrNoTagsFound {
+			t.Fatalf("want %v, got %v", ErrNoTagsFound, err)
+		}
+		now = now.Add(time.Hour)
+	}
+	tag, err := poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "1.0.0" {
+		t.Errorf("want %q, got %q", "1.0.0", tag)
+	}
+}
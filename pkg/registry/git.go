@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"context"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// GitRepository lists the tags of a git remote, for things only published
+// as tagged sources such as CRD bundles or kustomize bases.
+type GitRepository struct {
+	URL    string     `json:"url"`
+	Filter *TagFilter `json:"filter,omitempty"`
+	Policy TagPolicy  `json:"policy,omitempty"`
+
+	Auth transport.AuthMethod `json:"-"`
+}
+
+func NewGitRepository(u string, f *TagFilter, p TagPolicy, auth transport.AuthMethod) *GitRepository {
+	if p == nil {
+		p = &AlphabeticalPolicy{}
+	}
+	return &GitRepository{
+		URL:    u,
+		Filter: f,
+		Policy: p,
+		Auth:   auth,
+	}
+}
+
+func (g *GitRepository) FetchLatestTag(ctx context.Context) (string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{g.URL},
+	})
+	refs, err := remote.List(&git.ListOptions{Auth: g.Auth})
+	if err != nil {
+		return "", err
+	}
+
+	var tags []string
+	for _, ref := range refs {
+		name := ref.Name()
+		// Annotated tags are also advertised peeled as `<tag>^{}`.
+		if !name.IsTag() || strings.HasSuffix(name.String(), "^{}") {
+			continue
+		}
+		tags = append(tags, name.Short())
+	}
+	if len(tags) == 0 {
+		return "", ErrNoTagsFound
+	}
+	return retrieveSeenTag(ctx, g.Filter, g.Policy, tags)
+}
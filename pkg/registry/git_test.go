@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestGitRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	author := &object.Signature{Name: "manifest-updater", When: time.Now()}
+	hash, err := worktree.Commit("Initial commit", &git.CommitOptions{Author: author})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateTag("v1.9.0", hash, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateTag("v1.10.0", hash, &git.CreateTagOptions{Tagger: author, Message: "v1.10.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateTag("v2.0.0-rc.1", hash, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := NewGitRepository(dir, nil, &SemverPolicy{}, nil).FetchLatestTag(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "v1.10.0" {
+		t.Errorf("want %q, got %q", "v1.10.0", tag)
+	}
+}
@@ -0,0 +1,169 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"gopkg.in/yaml.v3"
+)
+
+const helmOCIScheme = "oci://"
+
+var (
+	ErrChartNotFound = errors.New("Chart not found in repository index")
+)
+
+// HelmRepository lists the versions of a Helm chart, either from the
+// index.yaml of a chart repository or from the tags of an OCI registry
+// when URL starts with oci://.
+type HelmRepository struct {
+	URL      string     `json:"url"`
+	Chart    string     `json:"chart"`
+	Filter   *TagFilter `json:"filter,omitempty"`
+	Policy   TagPolicy  `json:"policy,omitempty"`
+	Insecure bool       `json:"insecure,omitempty"`
+
+	Keychain  authn.Keychain `json:"-"`
+	transport http.RoundTripper
+}
+
+// NewHelmRepository returns a HelmRepository for chart in the repository
+// u. insecure and ca have the same meaning as for NewOCIRegistry.
+func NewHelmRepository(u, chart string, f *TagFilter, p TagPolicy, insecure bool, ca []byte, k authn.Keychain) (*HelmRepository, error) {
+	if p == nil {
+		p = &SemverPolicy{}
+	}
+	transport, err := newTransport(ca)
+	if err != nil {
+		return nil, err
+	}
+	return &HelmRepository{
+		URL:       u,
+		Chart:     chart,
+		Filter:    f,
+		Policy:    p,
+		Insecure:  insecure,
+		Keychain:  k,
+		transport: transport,
+	}, nil
+}
+
+func (h *HelmRepository) FetchLatestTag(ctx context.Context) (string, error) {
+	if h.isOCI() {
+		repo, err := h.repository()
+		if err != nil {
+			return "", err
+		}
+		tag, err := listLatestTag(ctx, repo, h.Filter, h.Policy, remoteOptions(h.Keychain, h.transport))
+		if err != nil {
+			return "", err
+		}
+		// OCI tags cannot contain `+`, so Helm pushes semver build
+		// metadata with `_` instead.
+		return strings.ReplaceAll(tag, "_", "+"), nil
+	}
+
+	versions, err := h.fetchIndexVersions(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", ErrNoTagsFound
+	}
+	return retrieveSeenTag(ctx, h.Filter, h.Policy, versions)
+}
+
+func (h *HelmRepository) Rejections() []Rejection {
+	return h.Filter.Rejections()
+}
+
+func (h *HelmRepository) isOCI() bool {
+	return strings.HasPrefix(h.URL, helmOCIScheme)
+}
+
+func (h *HelmRepository) repository() (name.Repository, error) {
+	var opts []name.Option
+	if h.Insecure {
+		opts = append(opts, name.Insecure)
+	}
+	u := strings.TrimSuffix(strings.TrimPrefix(h.URL, helmOCIScheme), "/")
+	return name.NewRepository(u+"/"+h.Chart, opts...)
+}
+
+type helmIndex struct {
+	Entries map[string][]struct {
+		Version string `yaml:"version"`
+	} `yaml:"entries"`
+}
+
+func (h *HelmRepository) fetchIndexVersions(ctx context.Context) ([]string, error) {
+	u, err := url.Parse(strings.TrimSuffix(h.URL, "/") + "/index.yaml")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.authorize(req, u.Host); err != nil {
+		return nil, err
+	}
+
+	res, err := (&http.Client{Transport: h.transport}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to fetch %s: %s", u, res.Status)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var index helmIndex
+	if err := yaml.Unmarshal(body, &index); err != nil {
+		return nil, err
+	}
+	entries, ok := index.Entries[h.Chart]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrChartNotFound, h.Chart)
+	}
+	versions := make([]string, 0, len(entries))
+	for _, e := range entries {
+		versions = append(versions, e.Version)
+	}
+	return versions, nil
+}
+
+// authorize sets basic auth on req with the credentials the keychain holds
+// for host, if any.
+func (h *HelmRepository) authorize(req *http.Request, host string) error {
+	if h.Keychain == nil {
+		return nil
+	}
+	reg, err := name.NewRegistry(host)
+	if err != nil {
+		return nil
+	}
+	auth, err := h.Keychain.Resolve(reg)
+	if err != nil {
+		return err
+	}
+	config, err := auth.Authorization()
+	if err != nil {
+		return err
+	}
+	if config.Username != "" || config.Password != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHelmRepositoryIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/charts/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`apiVersion: v1
+entries:
+  podinfo:
+  - version: 6.1.0
+  - version: 6.2.0-rc.1
+  - version: 6.0.3
+  other:
+  - version: 9.0.0
+`))
+	}))
+	defer server.Close()
+
+	h, err := NewHelmRepository(server.URL+"/charts/", "podinfo", nil, &SemverPolicy{}, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := h.FetchLatestTag(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "6.1.0" {
+		t.Errorf("want %q, got %q", "6.1.0", version)
+	}
+
+	h.Chart = "missing"
+	if _, err := h.FetchLatestTag(context.Background()); err == nil {
+		t.Error("want an error for a missing chart")
+	}
+}
+
+func TestHelmRepositoryIndexMinAge(t *testing.T) {
+	versions := "  - version: 6.0.0\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("entries:\n  podinfo:\n" + versions))
+	}))
+	defer server.Close()
+
+	now := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	withNow(t, &now)
+
+	f, err := NewTagFilter(nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetMinAge(time.Hour, AgeSourceFirstSeen); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHelmRepository(server.URL, "podinfo", f, &SemverPolicy{}, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.FetchLatestTag(context.Background()); err != ErrNoTagsFound {
+		t.Errorf("want %v, got %v", ErrNoTagsFound, err)
+	}
+
+	// 6.1.0 shows up later and is held back until it is an hour old.
+	now = now.Add(time.Hour)
+	versions += "  - version: 6.1.0\n"
+	for _, want := range []string{"6.0.0", "6.1.0"} {
+		version, err := h.FetchLatestTag(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != want {
+			t.Errorf("want %q, got %q", want, version)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	if err := f.SetPlatforms([]string{"linux/amd64"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.FetchLatestTag(context.Background()); err != ErrChecksUnsupported {
+		t.Errorf("want %v, got %v", ErrChecksUnsupported, err)
+	}
+}
@@ -0,0 +1,59 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+type dockerConfig struct {
+	Auths map[string]authn.AuthConfig `json:"auths"`
+}
+
+type dockerConfigKeychain struct {
+	auths map[string]authn.AuthConfig
+}
+
+// NewDockerConfigKeychain returns a keychain resolving credentials from
+// .dockerconfigjson documents, as stored in secrets of type
+// kubernetes.io/dockerconfigjson. Earlier documents take precedence.
+func NewDockerConfigKeychain(configs ...[]byte) (authn.Keychain, error) {
+	k := &dockerConfigKeychain{auths: map[string]authn.AuthConfig{}}
+	for _, c := range configs {
+		var config dockerConfig
+		if err := json.Unmarshal(c, &config); err != nil {
+			return nil, err
+		}
+		for host, auth := range config.Auths {
+			host = normalizeRegistryHost(host)
+			if _, ok := k.auths[host]; !ok {
+				k.auths[host] = auth
+			}
+		}
+	}
+	return k, nil
+}
+
+func (k *dockerConfigKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	auth, ok := k.auths[normalizeRegistryHost(target.RegistryStr())]
+	if !ok {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(auth), nil
+}
+
+// normalizeRegistryHost turns the keys found in docker config files, such
+// as `https://index.docker.io/v1/`, into a bare registry host.
+func normalizeRegistryHost(host string) string {
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host = strings.SplitN(host, "/", 2)[0]
+	if host == "docker.io" {
+		host = name.DefaultRegistry
+	}
+	return host
+}
@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestDockerConfigKeychain(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []string
+		repo    string
+		want    string
+	}{
+		{
+			name:    "docker hub v1 key",
+			configs: []string{`{"auths":{"https://index.docker.io/v1/":{"username":"alice","password":"secret"}}}`},
+			repo:    "acme/app",
+			want:    "alice",
+		},
+		{
+			name:    "docker.io key",
+			configs: []string{`{"auths":{"docker.io":{"username":"alice","password":"secret"}}}`},
+			repo:    "index.docker.io/acme/app",
+			want:    "alice",
+		},
+		{
+			name:    "url key with port",
+			configs: []string{`{"auths":{"https://registry.example.com:5000/v2/":{"username":"alice","password":"secret"}}}`},
+			repo:    "registry.example.com:5000/acme/app",
+			want:    "alice",
+		},
+		{
+			name: "earlier config wins",
+			configs: []string{
+				`{"auths":{"registry.example.com":{"username":"alice","password":"secret"}}}`,
+				`{"auths":{"https://registry.example.com":{"username":"bob","password":"secret"},"quay.io":{"username":"carol","password":"secret"}}}`,
+			},
+			repo: "registry.example.com/acme/app",
+			want: "alice",
+		},
+		{
+			name: "later config fills gaps",
+			configs: []string{
+				`{"auths":{"registry.example.com":{"username":"alice","password":"secret"}}}`,
+				`{"auths":{"quay.io":{"username":"carol","password":"secret"}}}`,
+			},
+			repo: "quay.io/acme/app",
+			want: "carol",
+		},
+		{
+			name:    "unknown host",
+			configs: []string{`{"auths":{"registry.example.com":{"username":"alice","password":"secret"}}}`},
+			repo:    "quay.io/acme/app",
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var configs [][]byte
+			for _, c := range tt.configs {
+				configs = append(configs, []byte(c))
+			}
+			k, err := NewDockerConfigKeychain(configs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo, err := name.NewRepository(tt.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth, err := k.Resolve(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if auth != authn.Anonymous {
+					t.Errorf("want anonymous, got %v", auth)
+				}
+				return
+			}
+			config, err := auth.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Username != tt.want {
+				t.Errorf("want %q, got %q", tt.want, config.Username)
+			}
+		})
+	}
+
+	if _, err := NewDockerConfigKeychain([]byte("garbage")); err == nil {
+		t.Error("want an error for an invalid config")
+	}
+}
@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+var (
+	ErrInvalidCABundle = errors.New("Invalid CA bundle")
+)
+
+// OCIRegistry lists tags of a repository hosted on any registry speaking
+// the OCI distribution API, such as Harbor, Artifactory or registry:2.
+type OCIRegistry struct {
+	URL      string     `json:"url"`
+	Filter   *TagFilter `json:"filter,omitempty"`
+	Policy   TagPolicy  `json:"policy,omitempty"`
+	Insecure bool       `json:"insecure,omitempty"`
+
+	Keychain  authn.Keychain `json:"-"`
+	transport http.RoundTripper
+}
+
+// NewOCIRegistry returns an OCIRegistry for the repository u. When insecure
+// is set the registry is reached over plain HTTP. ca is an optional PEM
+// encoded bundle trusted in addition to the system roots.
+func NewOCIRegistry(u string, f *TagFilter, p TagPolicy, insecure bool, ca []byte, k authn.Keychain) (*OCIRegistry, error) {
+	if p == nil {
+		p = &AlphabeticalPolicy{}
+	}
+	transport, err := newTransport(ca)
+	if err != nil {
+		return nil, err
+	}
+	return &OCIRegistry{
+		URL:       u,
+		Filter:    f,
+		Policy:    p,
+		Insecure:  insecure,
+		Keychain:  k,
+		transport: transport,
+	}, nil
+}
+
+func (o *OCIRegistry) FetchLatestTag(ctx context.Context) (string, error) {
+	registry, err := o.repository()
+	if err != nil {
+		return "", err
+	}
+	return listLatestTag(ctx, registry, o.Filter, o.Policy, remoteOptions(o.Keychain, o.transport))
+}
+
+func (o *OCIRegistry) Rejections() []Rejection {
+	return o.Filter.Rejections()
+}
+
+func (o *OCIRegistry) ResolveDigest(ctx context.Context, tag string) (string, error) {
+	registry, err := o.repository()
+	if err != nil {
+		return "", err
+	}
+	return resolveDigest(ctx, registry.Tag(tag), remoteOptions(o.Keychain, o.transport))
+}
+
+func (o *OCIRegistry) repository() (name.Repository, error) {
+	var opts []name.Option
+	if o.Insecure {
+		opts = append(opts, name.Insecure)
+	}
+	return name.NewRepository(o.URL, opts...)
+}
+
+func newTransport(ca []byte) (http.RoundTripper, error) {
+	if len(ca) == 0 {
+		return http.DefaultTransport, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, ErrInvalidCABundle
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return transport, nil
+}
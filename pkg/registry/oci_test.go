@@ -0,0 +1,145 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// tagLister serves the tags/list endpoint on top of the in-process
+// registry of go-containerregistry, listing the tags in push order where
+// the registry sorts them.
+type tagLister struct {
+	sync.Mutex
+	handler http.Handler
+	tags    map[string][]string
+}
+
+func newTagLister() *tagLister {
+	return &tagLister{
+		handler: ggcrregistry.New(ggcrregistry.Logger(log.New(ioutil.Discard, "", 0))),
+		tags:    map[string][]string{},
+	}
+}
+
+func (t *tagLister) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.Lock()
+	defer t.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	if strings.HasSuffix(path, "/tags/list") {
+		repo := strings.TrimSuffix(path, "/tags/list")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name": repo,
+			"tags": t.tags[repo],
+		})
+		return
+	}
+	if r.Method == http.MethodPut && strings.Contains(path, "/manifests/") {
+		parts := strings.SplitN(path, "/manifests/", 2)
+		if !strings.HasPrefix(parts[1], "sha256:") {
+			t.tags[parts[0]] = append(t.tags[parts[0]], parts[1])
+		}
+	}
+	t.handler.ServeHTTP(w, r)
+}
+
+func pushTags(t *testing.T, repo string, tags ...string) {
+	t.Helper()
+	for _, tag := range tags {
+		ref, err := name.NewTag(repo + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := random.Image(64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOCIRegistryFetchLatestTag(t *testing.T) {
+	server := httptest.NewServer(newTagLister())
+	defer server.Close()
+
+	repo := strings.TrimPrefix(server.URL, "http://") + "/acme/app"
+	pushTags(t, repo, "1.9.0", "1.10.0", "latest")
+
+	policy, _ := NewTagPolicy(PolicySemver, "", false)
+	r, err := NewOCIRegistry(repo, nil, policy, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := r.FetchLatestTag(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "1.10.0" {
+		t.Errorf("want %q, got %q", "1.10.0", tag)
+	}
+}
+
+func TestOCIRegistryResolveDigest(t *testing.T) {
+	counter := &requestCounter{handler: newTagLister(), counts: map[string]int{}}
+	server := httptest.NewServer(counter)
+	defer server.Close()
+
+	repo := strings.TrimPrefix(server.URL, "http://") + "/acme/app"
+	r, err := NewOCIRegistry(repo, nil, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(repo + ":1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		img, err := random.Image(64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+		want, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		counter.reset()
+		digest, err := r.ResolveDigest(context.Background(), "1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if digest != want.String() {
+			t.Errorf("want %s, got %s", want, digest)
+		}
+		if n := counter.count("GET manifests"); n != 0 {
+			t.Errorf("want the digest resolved with HEAD, got %d manifest GETs", n)
+		}
+	}
+
+	if _, err := r.ResolveDigest(context.Background(), "2.0.0"); !isNotFound(err) {
+		t.Errorf("want not found, got %v", err)
+	}
+}
+
+func TestNewOCIRegistryInvalidCABundle(t *testing.T) {
+	if _, err := NewOCIRegistry("registry.example.com/acme/app", nil, nil, false, []byte("garbage"), nil); err != ErrInvalidCABundle {
+		t.Errorf("want %v, got %v", ErrInvalidCABundle, err)
+	}
+}
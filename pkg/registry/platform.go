@@ -0,0 +1,157 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+var (
+	ErrInvalidPlatform = errors.New("Invalid platform")
+)
+
+// SetPlatforms makes the filter hold back tags whose image index lacks
+// any of platforms, given as `os/arch` or `os/arch/variant`.
+func (f *TagFilter) SetPlatforms(platforms []string) error {
+	f.Platforms = nil
+	for _, p := range platforms {
+		parts := strings.Split(p, "/")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Errorf("%w: %s", ErrInvalidPlatform, p)
+		}
+		platform := v1.Platform{OS: parts[0], Architecture: parts[1]}
+		if len(parts) == 3 {
+			platform.Variant = parts[2]
+		}
+		f.Platforms = append(f.Platforms, platform)
+	}
+	return nil
+}
+
+// platformCache remembers the platforms missing from manifests by digest,
+// which never change.
+type platformCache struct {
+	mu sync.Mutex
+	m  map[v1.Hash][]string
+}
+
+// keep forgets the platforms of the digests not in digests.
+func (c *platformCache) keep(digests map[v1.Hash]bool) {
+	c.mu.Lock()
+	for d := range c.m {
+		if !digests[d] {
+			delete(c.m, d)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// missingPlatforms returns the platforms of the filter the manifest digest
+// in repo is not available for.
+func (f *TagFilter) missingPlatforms(ctx context.Context, repo name.Repository, opts []remote.Option, digest v1.Hash) ([]string, error) {
+	f.platforms.mu.Lock()
+	missing, ok := f.platforms.m[digest]
+	f.platforms.mu.Unlock()
+	if ok {
+		return missing, nil
+	}
+
+	desc, err := remote.Get(repo.Digest(digest.String()), withContext(ctx, opts)...)
+	if err != nil {
+		return nil, err
+	}
+	missing, err = missingPlatforms(desc, f.Platforms)
+	if err != nil {
+		return nil, err
+	}
+
+	f.platforms.mu.Lock()
+	if f.platforms.m == nil {
+		f.platforms.m = map[v1.Hash][]string{}
+	}
+	f.platforms.m[digest] = missing
+	f.platforms.mu.Unlock()
+	return missing, nil
+}
+
+// missingPlatforms returns the platforms of required the image desc points
+// to is not available for. An image that is not an index is available
+// for the platform of its config only.
+func missingPlatforms(desc *remote.Descriptor, required []v1.Platform) ([]string, error) {
+	var available []v1.Platform
+	switch desc.MediaType {
+	case types.OCIImageIndex, types.DockerManifestList:
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range manifest.Manifests {
+			if m.Platform != nil {
+				available = append(available, *m.Platform)
+			}
+		}
+	default:
+		img, err := desc.Image()
+		if err != nil {
+			return nil, err
+		}
+		// The config file of go-containerregistry lacks the variant.
+		raw, err := img.RawConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		var config struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+		available = append(available, v1.Platform{
+			OS:           config.OS,
+			Architecture: config.Architecture,
+			Variant:      config.Variant,
+		})
+	}
+
+	var missing []string
+	for _, r := range required {
+		if !hasPlatform(available, r) {
+			missing = append(missing, formatPlatform(r))
+		}
+	}
+	return missing, nil
+}
+
+func hasPlatform(available []v1.Platform, required v1.Platform) bool {
+	for _, a := range available {
+		if a.OS != required.OS || a.Architecture != required.Architecture {
+			continue
+		}
+		if required.Variant == "" || a.Variant == required.Variant {
+			return true
+		}
+	}
+	return false
+}
+
+func formatPlatform(p v1.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
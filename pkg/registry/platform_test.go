@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+type rawManifest []byte
+
+func (m rawManifest) RawManifest() ([]byte, error) { return m, nil }
+
+// pushIndex pushes an index holding a random image for each platform.
+func pushIndex(t *testing.T, repo name.Repository, tag string, platforms ...v1.Platform) {
+	index := v1.ImageIndex(empty.Index)
+	for i := range platforms {
+		img, err := random.Image(64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &platforms[i]},
+		})
+	}
+	if err := remote.WriteIndex(repo.Tag(tag), index); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pushConfig pushes a single-arch image with the raw config, which may hold
+// fields the config file of go-containerregistry lacks.
+func pushConfig(t *testing.T, repo name.Repository, tag, config string) {
+	layer := &rawLayer{content: []byte(config), mediaType: types.DockerConfigJSON}
+	if err := remote.WriteLayer(repo, layer); err != nil {
+		t.Fatal(err)
+	}
+	digest, _ := layer.Digest()
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"config":{"mediaType":%q,"size":%d,"digest":%q},"layers":[]}`,
+		types.DockerManifestSchema2, types.DockerConfigJSON, len(config), digest)
+	if err := remote.Put(repo.Tag(tag), rawManifest(manifest)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMissingPlatforms(t *testing.T) {
+	server := httptest.NewServer(newTagLister())
+	defer server.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	amd64 := v1.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := v1.Platform{OS: "linux", Architecture: "arm64"}
+	pushIndex(t, repo, "partial", amd64)
+	pushIndex(t, repo, "full", amd64, arm64)
+	pushConfig(t, repo, "armv7", `{"os":"linux","architecture":"arm","variant":"v7","rootfs":{"type":"layers","diff_ids":[]}}`)
+
+	tests := []struct {
+		tag       string
+		platforms []string
+		missing   []string
+	}{
+		{"partial", []string{"linux/amd64", "linux/arm64"}, []string{"linux/arm64"}},
+		{"full", []string{"linux/amd64", "linux/arm64"}, nil},
+		{"armv7", []string{"linux/arm/v7"}, nil},
+		{"armv7", []string{"linux/arm/v6"}, []string{"linux/arm/v6"}},
+		{"armv7", []string{"linux/amd64"}, []string{"linux/amd64"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag+" "+strings.Join(tt.platforms, ","), func(t *testing.T) {
+			f, err := NewTagFilter(nil, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.SetPlatforms(tt.platforms); err != nil {
+				t.Fatal(err)
+			}
+			desc, err := remote.Head(repo.Tag(tt.tag))
+			if err != nil {
+				t.Fatal(err)
+			}
+			missing, err := f.missingPlatforms(context.Background(), repo, nil, desc.Digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(missing, tt.missing) {
+				t.Errorf("want missing %v, got %v", tt.missing, missing)
+			}
+		})
+	}
+}
+
+func TestRetrieveAcceptedTagPlatformCache(t *testing.T) {
+	counter := &requestCounter{handler: newTagLister(), counts: map[string]int{}}
+	server := httptest.NewServer(counter)
+	defer server.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushTags(t, repo.String(), "1.0.0", "1.1.0")
+
+	f, err := NewTagFilter(nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetPlatforms([]string{"linux/arm64"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		counter.reset()
+		if _, err := retrieveAcceptedTag(context.Background(), repo, nil, f, &SemverPolicy{}, []string{"1.0.0", "1.1.0"}); err != ErrNoTagsFound {
+			t.Fatalf("want %v, got %v", ErrNoTagsFound, err)
+		}
+		if len(f.Rejections()) != 2 {
+			t.Errorf("want 2 rejections, got %v", f.Rejections())
+		}
+	}
+	if n := counter.count("GET manifests"); n != 0 {
+		t.Errorf("want the rejections cached, got %d manifest requests", n)
+	}
+
+	// The platforms of tags no longer listed are forgotten.
+	if _, err := retrieveAcceptedTag(context.Background(), repo, nil, f, &SemverPolicy{}, []string{"1.1.0"}); err != ErrNoTagsFound {
+		t.Fatalf("want %v, got %v", ErrNoTagsFound, err)
+	}
+	if n := len(f.platforms.m); n != 1 {
+		t.Errorf("want 1 cached digest, got %d", n)
+	}
+}
@@ -0,0 +1,145 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	PolicyAlphabetical = "alphabetical"
+	PolicyNumerical    = "numerical"
+	PolicySemver       = "semver"
+)
+
+var (
+	ErrUnknownPolicy = errors.New("Unknown tag policy")
+)
+
+// TagPolicy picks the latest tag out of the tags listed by a registry.
+type TagPolicy interface {
+	Latest(tags []string) (string, error)
+}
+
+// NewTagPolicy returns the TagPolicy named by policy. An empty policy
+// falls back to alphabetical ordering. constraint and prerelease are only
+// meaningful for the semver policy.
+func NewTagPolicy(policy, constraint string, prerelease bool) (TagPolicy, error) {
+	switch policy {
+	case "", PolicyAlphabetical:
+		return &AlphabeticalPolicy{}, nil
+	case PolicyNumerical:
+		return &NumericalPolicy{}, nil
+	case PolicySemver:
+		return NewSemverPolicy(constraint, prerelease)
+	case PolicyCreated:
+		return NewCreatedPolicy(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownPolicy, policy)
+}
+
+// AlphabeticalPolicy treats the lexically greatest tag as the latest one.
+type AlphabeticalPolicy struct{}
+
+func (a *AlphabeticalPolicy) Latest(tags []string) (string, error) {
+	if len(tags) == 0 {
+		return "", ErrNoTagsFound
+	}
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+	return sorted[len(sorted)-1], nil
+}
+
+// NumericalPolicy treats the numerically greatest tag as the latest one.
+// Tags that are not made of decimal digits only are ignored. Tags are
+// compared as arbitrarily long integers, so that build numbers and
+// timestamps such as 20210301120000 keep their order.
+type NumericalPolicy struct{}
+
+func (n *NumericalPolicy) Latest(tags []string) (string, error) {
+	var (
+		latest string
+		max    string
+		found  bool
+	)
+	for _, t := range tags {
+		if !isDigits(t) {
+			continue
+		}
+		v := strings.TrimLeft(t, "0")
+		if !found || len(v) > len(max) || (len(v) == len(max) && v > max) {
+			latest, max, found = t, v, true
+		}
+	}
+	if !found {
+		return "", ErrNoTagsFound
+	}
+	return latest, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// SemverPolicy treats the greatest semantic version as the latest one.
+// Tags that are not semantic versions are ignored. Pre-release tags are
+// only taken into account when Prerelease is set, and are then compared
+// against Constraint as if they were the release they precede.
+type SemverPolicy struct {
+	Constraint string `json:"constraint,omitempty"`
+	Prerelease bool   `json:"prerelease,omitempty"`
+
+	constraints *semver.Constraints
+}
+
+func NewSemverPolicy(constraint string, prerelease bool) (*SemverPolicy, error) {
+	s := &SemverPolicy{Constraint: constraint, Prerelease: prerelease}
+	if constraint != "" {
+		c, err := semver.NewConstraint(constraint)
+		if err != nil {
+			return nil, err
+		}
+		s.constraints = c
+	}
+	return s, nil
+}
+
+func (s *SemverPolicy) Latest(tags []string) (string, error) {
+	var (
+		latest string
+		max    *semver.Version
+	)
+	for _, t := range tags {
+		v, err := semver.NewVersion(t)
+		if err != nil {
+			continue
+		}
+		if v.Prerelease() != "" && !s.Prerelease {
+			continue
+		}
+		if s.constraints != nil {
+			release, _ := v.SetPrerelease("")
+			if !s.constraints.Check(&release) {
+				continue
+			}
+		}
+		if max == nil || v.GreaterThan(max) {
+			latest, max = t, v
+		}
+	}
+	if max == nil {
+		return "", ErrNoTagsFound
+	}
+	return latest, nil
+}
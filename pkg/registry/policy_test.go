@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTagPolicyLatest(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		constraint string
+		prerelease bool
+		tags       []string
+		want       string
+		err        error
+	}{
+		{
+			name:   "alphabetical",
+			policy: PolicyAlphabetical,
+			tags:   []string{"b", "c", "a"},
+			want:   "c",
+		},
+		{
+			name:   "numerical",
+			policy: PolicyNumerical,
+			tags:   []string{"9", "10", "latest", "2"},
+			want:   "10",
+		},
+		{
+			name:   "numerical ignores floats and exponents",
+			policy: PolicyNumerical,
+			tags:   []string{"9", "1e3", "Inf", "NaN", "0x10", "-20", "+30", "1.5", "1_000"},
+			want:   "9",
+		},
+		{
+			name:   "numerical beyond float precision",
+			policy: PolicyNumerical,
+			tags:   []string{"20210301120000000001", "20210301120000000002", "020210301120000000000"},
+			want:   "20210301120000000002",
+		},
+		{
+			name:   "numerical without numbers",
+			policy: PolicyNumerical,
+			tags:   []string{"latest", "1.0"},
+			err:    ErrNoTagsFound,
+		},
+		{
+			name:   "semver",
+			policy: PolicySemver,
+			tags:   []string{"1.9.0", "1.10.0", "latest", "v1.2.3"},
+			want:   "1.10.0",
+		},
+		{
+			name:   "semver skips pre-releases",
+			policy: PolicySemver,
+			tags:   []string{"1.9.0", "1.10.0-rc.1"},
+			want:   "1.9.0",
+		},
+		{
+			name:       "semver with pre-releases",
+			policy:     PolicySemver,
+			prerelease: true,
+			tags:       []string{"1.9.0", "1.10.0-rc.1"},
+			want:       "1.10.0-rc.1",
+		},
+		{
+			name:       "semver with tilde range",
+			policy:     PolicySemver,
+			constraint: "~1.4",
+			tags:       []string{"1.3.9", "1.4.2", "1.4.10", "1.5.0"},
+			want:       "1.4.10",
+		},
+		{
+			name:       "semver with pre-release in range",
+			policy:     PolicySemver,
+			constraint: ">=2.0 <3",
+			prerelease: true,
+			tags:       []string{"2.1.0", "2.2.0-beta.1", "3.0.0-rc.1"},
+			want:       "2.2.0-beta.1",
+		},
+		{
+			name:   "semver without versions",
+			policy: PolicySemver,
+			tags:   []string{"latest", "dev"},
+			err:    ErrNoTagsFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewTagPolicy(tt.policy, tt.constraint, tt.prerelease)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Latest(tt.tags)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewTagPolicyUnknown(t *testing.T) {
+	if _, err := NewTagPolicy("random", "", false); !errors.Is(err, ErrUnknownPolicy) {
+		t.Errorf("want %v, got %v", ErrUnknownPolicy, err)
+	}
+}
@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	ErrRateLimited = errors.New("Registry rate limit exceeded")
+)
+
+var nowFunc = time.Now
+
+var (
+	minBackoff = time.Minute
+	maxBackoff = time.Hour
+)
+
+var rateLimitRemaining = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "manifest_updater_registry_ratelimit_remaining",
+		Help: "Remaining requests reported by the RateLimit-Remaining header of a registry.",
+	},
+	[]string{"host"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(rateLimitRemaining)
+}
+
+// RateLimitError is returned while a registry host is backed off after
+// answering 429 Too Many Requests.
+type RateLimitError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: %s until %s", ErrRateLimited, e.Host, e.Until.Format(time.RFC3339))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+type backoff struct {
+	until   time.Time
+	current time.Duration
+}
+
+// backoffs is shared by every registry, so that all the updaters polling
+// the same host back off together.
+var backoffs = struct {
+	sync.Mutex
+	m map[string]*backoff
+}{m: map[string]*backoff{}}
+
+// RateLimited reports whether the host of repository is backed off, and
+// until when.
+func RateLimited(repository string) (time.Time, bool) {
+	host := strings.SplitN(repository, "/", 2)[0]
+
+	backoffs.Lock()
+	defer backoffs.Unlock()
+	b, ok := backoffs.m[host]
+	if !ok || !nowFunc().Before(b.until) {
+		return time.Time{}, false
+	}
+	return b.until, true
+}
+
+// rateLimitTransport records the rate limit headers of registry responses
+// and refuses to send requests to a host while it is backed off.
+type rateLimitTransport struct {
+	inner http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if until, limited := RateLimited(host); limited {
+		return nil, &RateLimitError{Host: host, Until: until}
+	}
+
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if remaining, ok := parseRateLimitRemaining(resp.Header.Get("RateLimit-Remaining")); ok {
+		rateLimitRemaining.WithLabelValues(host).Set(remaining)
+	}
+
+	backoffs.Lock()
+	defer backoffs.Unlock()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		delete(backoffs.m, host)
+		return resp, nil
+	}
+	b, ok := backoffs.m[host]
+	if !ok {
+		b = &backoff{}
+		backoffs.m[host] = b
+	}
+	// Double the backoff on every consecutive 429 unless the registry
+	// tells how long to wait.
+	b.current *= 2
+	if b.current < minBackoff {
+		b.current = minBackoff
+	}
+	if b.current > maxBackoff {
+		b.current = maxBackoff
+	}
+	wait := b.current
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		// Dates in the past would lift the backoff right away.
+		wait = retryAfter
+		if wait < minBackoff {
+			wait = minBackoff
+		}
+	}
+	b.until = nowFunc().Add(wait)
+	return resp, nil
+}
+
+// parseRateLimitRemaining parses headers such as `76;w=21600`.
+func parseRateLimitRemaining(v string) (float64, bool) {
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(strings.SplitN(v, ";", 2)[0]), 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// parseRetryAfter parses both forms of the Retry-After header, a number
+// of seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t.Sub(nowFunc()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,126 @@
+package registry
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// statusTransport answers every request with status and header.
+type statusTransport struct {
+	status int
+	header http.Header
+	calls  int
+}
+
+func (s *statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.calls++
+	header := s.header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: s.status, Header: header, Body: http.NoBody, Request: req}, nil
+}
+
+func withNow(t *testing.T, now *time.Time) {
+	t.Helper()
+	f := nowFunc
+	nowFunc = func() time.Time { return *now }
+	t.Cleanup(func() { nowFunc = f })
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	withNow(t, &now)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%q: want %s %v, got %s %v", tt.value, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestRateLimitTransportBackoff(t *testing.T) {
+	now := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	withNow(t, &now)
+
+	const host = "backoff.example.com"
+	inner := &statusTransport{status: http.StatusTooManyRequests}
+	transport := &rateLimitTransport{inner: inner}
+	req, _ := http.NewRequest(http.MethodGet, "https://"+host+"/v2/", nil)
+
+	// Consecutive 429s double the backoff, clamped to [minBackoff, maxBackoff].
+	for _, want := range []time.Duration{
+		time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute,
+		16 * time.Minute, 32 * time.Minute, time.Hour, time.Hour,
+	} {
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		until, limited := RateLimited(host + "/acme/app")
+		if !limited || until.Sub(now) != want {
+			t.Fatalf("want backed off for %s, got %s", want, until.Sub(now))
+		}
+
+		// The host is refused until the backoff expires.
+		calls := inner.calls
+		_, err := transport.RoundTrip(req)
+		if !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("want %v, got %v", ErrRateLimited, err)
+		}
+		var rerr *RateLimitError
+		if !errors.As(err, &rerr) || rerr.Host != host || !rerr.Until.Equal(until) {
+			t.Errorf("want a RateLimitError for %s until %s, got %v", host, until, err)
+		}
+		if inner.calls != calls {
+			t.Error("a request was sent to a backed off host")
+		}
+		now = until
+	}
+
+	// Any other answer lifts the backoff.
+	inner.status = http.StatusOK
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, limited := RateLimited(host); limited {
+		t.Error("want the backoff lifted")
+	}
+}
+
+func TestRateLimitTransportRetryAfter(t *testing.T) {
+	now := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	withNow(t, &now)
+
+	tests := []struct {
+		host       string
+		retryAfter string
+		want       time.Duration
+	}{
+		{"seconds.example.com", "600", 10 * time.Minute},
+		{"date.example.com", now.Add(5 * time.Minute).Format(http.TimeFormat), 5 * time.Minute},
+		{"past.example.com", now.Add(-time.Hour).Format(http.TimeFormat), minBackoff},
+	}
+	for _, tt := range tests {
+		inner := &statusTransport{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {tt.retryAfter}}}
+		req, _ := http.NewRequest(http.MethodGet, "https://"+tt.host+"/v2/", nil)
+		if _, err := (&rateLimitTransport{inner: inner}).RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		if until, _ := RateLimited(tt.host); until.Sub(now) != tt.want {
+			t.Errorf("%s: want backed off for %s, got %s", tt.host, tt.want, until.Sub(now))
+		}
+	}
+}
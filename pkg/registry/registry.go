@@ -3,12 +3,38 @@ package registry
 import (
 	"context"
 	"errors"
+
+	"github.com/google/go-containerregistry/pkg/name"
 )
 
 var (
-	ErrNoTagsFound = errors.New("No tags found")
+	ErrNoTagsFound       = errors.New("No tags found")
+	ErrDigestUnsupported = errors.New("Registry does not support digests")
 )
 
 type Registry interface {
 	FetchLatestTag(context.Context) (string, error)
 }
+
+// DigestResolver is implemented by registries able to resolve a tag to
+// the digest of its manifest.
+type DigestResolver interface {
+	ResolveDigest(ctx context.Context, tag string) (string, error)
+}
+
+// Rejector is implemented by registries that hold back tags failing
+// checks such as the minimum age or the signature verification.
+type Rejector interface {
+	// Rejections returns the tags held back by the latest FetchLatestTag.
+	Rejections() []Rejection
+}
+
+// NormalizeRepository returns the fully qualified name of the repository
+// r, so that e.g. `nginx` and `index.docker.io/library/nginx` compare equal.
+func NormalizeRepository(r string) string {
+	repo, err := name.NewRepository(r)
+	if err != nil {
+		return r
+	}
+	return repo.Name()
+}
@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrInvalidGitHubURL = errors.New("Invalid GitHub repository url")
+)
+
+// GitHubReleases lists the releases of a GitHub repository. Drafts are
+// always skipped, releases marked as pre-releases unless Prerelease is set.
+type GitHubReleases struct {
+	URL        string     `json:"url"`
+	Filter     *TagFilter `json:"filter,omitempty"`
+	Policy     TagPolicy  `json:"policy,omitempty"`
+	Prerelease bool       `json:"prerelease,omitempty"`
+
+	// APIURL is the API endpoint, by default https://api.github.com/ for
+	// github.com and https://<host>/api/v3/ for GitHub Enterprise.
+	APIURL string `json:"-"`
+	Token  string `json:"-"`
+}
+
+func NewGitHubReleases(u string, f *TagFilter, p TagPolicy, prerelease bool, token string) *GitHubReleases {
+	if p == nil {
+		p = &AlphabeticalPolicy{}
+	}
+	return &GitHubReleases{
+		URL:        u,
+		Filter:     f,
+		Policy:     p,
+		Prerelease: prerelease,
+		Token:      token,
+	}
+}
+
+func (g *GitHubReleases) FetchLatestTag(ctx context.Context) (string, error) {
+	host, owner, repo, err := g.parseURL()
+	if err != nil {
+		return "", err
+	}
+	client, err := g.client(ctx, host)
+	if err != nil {
+		return "", err
+	}
+
+	var tags []string
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		releases, res, err := client.Repositories.ListReleases(ctx, owner, repo, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, r := range releases {
+			if r.GetDraft() || (r.GetPrerelease() && !g.Prerelease) {
+				continue
+			}
+			tags = append(tags, r.GetTagName())
+		}
+		if res.NextPage == 0 {
+			break
+		}
+		opts.Page = res.NextPage
+	}
+	if len(tags) == 0 {
+		return "", ErrNoTagsFound
+	}
+	return retrieveSeenTag(ctx, g.Filter, g.Policy, tags)
+}
+
+func (g *GitHubReleases) client(ctx context.Context, host string) (*github.Client, error) {
+	httpClient := oauth2.NewClient(ctx, nil)
+	if g.Token != "" {
+		httpClient = oauth2.NewClient(ctx, oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: g.Token},
+		))
+	}
+	apiURL := g.APIURL
+	if apiURL == "" {
+		if host == "github.com" {
+			return github.NewClient(httpClient), nil
+		}
+		apiURL = fmt.Sprintf("https://%s/api/v3/", host)
+	}
+	return github.NewEnterpriseClient(apiURL, apiURL, httpClient)
+}
+
+// parseURL splits URLs such as https://github.com/owner/repo.git,
+// git@github.com:owner/repo.git or github.com/owner/repo into their host,
+// owner and repository name.
+func (g *GitHubReleases) parseURL() (string, string, string, error) {
+	endpoint, err := transport.NewEndpoint(g.URL)
+	if err == nil && endpoint.Protocol == "file" {
+		endpoint, err = transport.NewEndpoint("https://" + g.URL)
+	}
+	if err != nil {
+		return "", "", "", fmt.Errorf("%w: %v", ErrInvalidGitHubURL, err)
+	}
+	path := strings.Split(strings.Trim(endpoint.Path, "/"), "/")
+	if endpoint.Host == "" || len(path) != 2 {
+		return "", "", "", fmt.Errorf("%w: %s", ErrInvalidGitHubURL, g.URL)
+	}
+	return endpoint.Host, path[0], strings.TrimSuffix(path[1], ".git"), nil
+}
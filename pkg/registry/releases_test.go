@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitHubReleases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/crds/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[
+			{"tag_name": "v0.3.0", "draft": true},
+			{"tag_name": "v0.2.1-rc.1", "prerelease": true},
+			{"tag_name": "v0.2.0"},
+			{"tag_name": "v0.1.0"}
+		]`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		prerelease bool
+		want       string
+	}{
+		{name: "releases", url: "https://github.com/acme/crds.git", want: "v0.2.0"},
+		{name: "pre-releases", url: "https://github.com/acme/crds.git", prerelease: true, want: "v0.2.1-rc.1"},
+		{name: "scp-like url", url: "git@github.com:acme/crds.git", want: "v0.2.0"},
+		{name: "url without scheme", url: "github.com/acme/crds", want: "v0.2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGitHubReleases(tt.url, nil, &SemverPolicy{Prerelease: true}, tt.prerelease, "")
+			g.APIURL = server.URL + "/"
+			tag, err := g.FetchLatestTag(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tag != tt.want {
+				t.Errorf("want %q, got %q", tt.want, tag)
+			}
+		})
+	}
+}
+
+func TestGitHubReleasesParseURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		host  string
+		owner string
+		repo  string
+	}{
+		{url: "https://github.com/acme/crds.git", host: "github.com", owner: "acme", repo: "crds"},
+		{url: "git@github.com:acme/crds.git", host: "github.com", owner: "acme", repo: "crds"},
+		{url: "ssh://git@ghe.example.com/acme/crds.git", host: "ghe.example.com", owner: "acme", repo: "crds"},
+		{url: "github.com/acme/crds", host: "github.com", owner: "acme", repo: "crds"},
+	}
+	for _, tt := range tests {
+		host, owner, repo, err := NewGitHubReleases(tt.url, nil, nil, false, "").parseURL()
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if host != tt.host || owner != tt.owner || repo != tt.repo {
+			t.Errorf("%s: want %s %s %s, got %s %s %s", tt.url, tt.host, tt.owner, tt.repo, host, owner, repo)
+		}
+	}
+
+	if _, _, _, err := NewGitHubReleases("https://github.com/acme", nil, nil, false, "").parseURL(); err == nil {
+		t.Error("want an error for a url without a repository")
+	}
+}
@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+func listLatestTag(ctx context.Context, repo name.Repository, filter *TagFilter, policy TagPolicy, opts []remote.Option) (string, error) {
+	tags, err := remote.ListWithContext(ctx, repo, opts...)
+	if err != nil {
+		if until, limited := RateLimited(repo.RegistryStr()); limited {
+			return "", &RateLimitError{Host: repo.RegistryStr(), Until: until}
+		}
+		return "", err
+	}
+	if len(tags) == 0 {
+		return "", ErrNoTagsFound
+	}
+	if p, ok := policy.(*CreatedPolicy); ok {
+		policy = p.bind(ctx, repo, opts)
+	}
+	return retrieveAcceptedTag(ctx, repo, opts, filter, policy, tags)
+}
+
+func remoteOptions(keychain authn.Keychain, transport http.RoundTripper) []remote.Option {
+	var opts []remote.Option
+	if keychain != nil {
+		opts = append(opts, remote.WithAuthFromKeychain(keychain))
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	opts = append(opts, remote.WithTransport(&rateLimitTransport{inner: transport}))
+	return opts
+}
+
+// isNotFound reports whether err is the answer of the registry to a
+// request for a manifest or blob it does not have.
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
+
+// resolveDigest returns the digest of the manifest ref points to. It asks
+// with HEAD, which Docker Hub does not count against the pull rate limit.
+// withContext returns opts making the requests with ctx, so that they are
+// cancelled along with it. opts is left alone.
+func withContext(ctx context.Context, opts []remote.Option) []remote.Option {
+	return append(opts[:len(opts):len(opts)], remote.WithContext(ctx))
+}
+
+func resolveDigest(ctx context.Context, ref name.Reference, opts []remote.Option) (string, error) {
+	desc, err := remote.Head(ref, withContext(ctx, opts)...)
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest.String(), nil
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const azureAPIVersion = "6.0"
+
+// AzureDevOpsRepository opens pull requests on Azure DevOps Repos. URL is
+// the clone url of the repository, such as
+// https://dev.azure.com/org/project/_git/repo,
+// https://org.visualstudio.com/project/_git/repo or
+// git@ssh.dev.azure.com:v3/org/project/repo.
+type AzureDevOpsRepository struct {
+	GitSettings
+
+	Auth AzureAuth `json:"-"`
+
+	// APIURL is the organization or collection url the REST API is served
+	// from, by default the part of URL before the project.
+	APIURL string `json:"apiURL,omitempty"`
+}
+
+// AzureAuth holds a personal access token.
+type AzureAuth struct {
+	Token string
+}
+
+func NewAzureDevOpsRepository(git GitSettings, apiURL string, auth AzureAuth) *AzureDevOpsRepository {
+	return &AzureDevOpsRepository{
+		GitSettings: git,
+		Auth:        auth,
+		APIURL:      apiURL,
+	}
+}
+
+// PushReplaceTagCommit rewrites the references of image below Path and
+// pushes the result to the Head branch, or to Base when Push is set.
+func (a *AzureDevOpsRepository) PushReplaceTagCommit(ctx context.Context, image, tag, digest string) error {
+	endpoint, err := transport.NewEndpoint(a.URL)
+	if err != nil {
+		return err
+	}
+	var auth transport.AuthMethod
+	if strings.HasPrefix(endpoint.Protocol, "http") && a.Auth.Token != "" {
+		// Azure DevOps ignores the user name of personal access tokens.
+		auth = &githttp.BasicAuth{Username: "manifest-updater", Password: a.Auth.Token}
+	}
+	return a.remote(auth).pushReplaceTagCommit(ctx, image, tag, digest)
+}
+
+type azurePullRequest struct {
+	PullRequestID int    `json:"pullRequestId,omitempty"`
+	SourceRefName string `json:"sourceRefName"`
+	TargetRefName string `json:"targetRefName"`
+	Title         string `json:"title,omitempty"`
+	Description   string `json:"description,omitempty"`
+}
+
+type azurePullRequestList struct {
+	Value []azurePullRequest `json:"value"`
+	Count int                `json:"count"`
+}
+
+func (a *AzureDevOpsRepository) CreatePullRequest(ctx context.Context) error {
+	collection, project, repo, err := a.parseURL()
+	if err != nil {
+		return err
+	}
+	if a.APIURL != "" {
+		collection = strings.TrimSuffix(a.APIURL, "/")
+	}
+	pulls := fmt.Sprintf(
+		"%s/%s/_apis/git/repositories/%s/pullrequests",
+		collection, url.PathEscape(project), url.PathEscape(repo),
+	)
+
+	head := "refs/heads/" + a.Head
+	base := "refs/heads/" + a.Base
+	query := url.Values{}
+	query.Set("searchCriteria.status", "active")
+	query.Set("searchCriteria.sourceRefName", head)
+	query.Set("searchCriteria.targetRefName", base)
+	query.Set("api-version", azureAPIVersion)
+	var list azurePullRequestList
+	if err := a.do(ctx, http.MethodGet, pulls+"?"+query.Encode(), nil, &list); err != nil {
+		return err
+	}
+	if len(list.Value) > 0 {
+		return ErrPullRequestAlreadyExists
+	}
+
+	return a.do(ctx, http.MethodPost, pulls+"?api-version="+azureAPIVersion, &azurePullRequest{
+		SourceRefName: head,
+		TargetRefName: base,
+		Title:         "Automaticaly update image tags",
+	}, nil)
+}
+
+func (a *AzureDevOpsRepository) do(ctx context.Context, method, u string, in, out interface{}) error {
+	return doJSON(ctx, method, u, func(req *http.Request) {
+		req.SetBasicAuth("", a.Auth.Token)
+	}, in, out)
+}
+
+// parseURL returns the organization or collection url, the project and
+// the repository of URL.
+func (a *AzureDevOpsRepository) parseURL() (string, string, string, error) {
+	endpoint, err := transport.NewEndpoint(a.URL)
+	if err != nil {
+		return "", "", "", err
+	}
+	path := strings.Split(strings.Trim(endpoint.Path, "/"), "/")
+
+	// git@ssh.dev.azure.com:v3/org/project/repo
+	if !strings.HasPrefix(endpoint.Protocol, "http") {
+		if len(path) != 4 || path[0] != "v3" {
+			return "", "", "", fmt.Errorf("%w: %s", ErrInvalidRepositoryURL, a.URL)
+		}
+		return "https://dev.azure.com/" + url.PathEscape(path[1]), path[2], path[3], nil
+	}
+
+	// https://dev.azure.com/org/project/_git/repo
+	for i := 1; i+1 < len(path); i++ {
+		if path[i] != "_git" {
+			continue
+		}
+		host := endpoint.Host
+		if endpoint.Port != 0 {
+			host = fmt.Sprintf("%s:%d", host, endpoint.Port)
+		}
+		collection := fmt.Sprintf("%s://%s", endpoint.Protocol, host)
+		for _, p := range path[:i-1] {
+			collection += "/" + url.PathEscape(p)
+		}
+		return collection, path[i-1], path[i+1], nil
+	}
+	return "", "", "", fmt.Errorf("%w: %s", ErrInvalidRepositoryURL, a.URL)
+}
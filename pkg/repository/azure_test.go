@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeAzureDevOps serves the pull request endpoints of the Azure DevOps
+// API for the repository repo of the project "my project" in org.
+type fakeAzureDevOps struct {
+	token string
+	pulls []azurePullRequest
+}
+
+func (f *fakeAzureDevOps) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, password, _ := r.BasicAuth(); password != f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.EscapedPath() != "/org/my%20project/_apis/git/repositories/repo/pullrequests" || r.URL.Query().Get("api-version") == "" {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		list := azurePullRequestList{Value: []azurePullRequest{}}
+		for _, pr := range f.pulls {
+			if pr.SourceRefName == q.Get("searchCriteria.sourceRefName") && pr.TargetRefName == q.Get("searchCriteria.targetRefName") {
+				list.Value = append(list.Value, pr)
+			}
+		}
+		list.Count = len(list.Value)
+		json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var pr azurePullRequest
+		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pr.PullRequestID = len(f.pulls) + 1
+		f.pulls = append(f.pulls, pr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pr)
+	}
+}
+
+func TestAzureDevOpsCreatePullRequest(t *testing.T) {
+	fake := &fakeAzureDevOps{token: "pat"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	a := NewAzureDevOpsRepository(NewGitSettings(server.URL+"/org/my%20project/_git/repo", "main", "", ""), "", AzureAuth{Token: "pat"})
+	if err := a.CreatePullRequest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.pulls) != 1 || fake.pulls[0].SourceRefName != "refs/heads/"+DefaultHead || fake.pulls[0].TargetRefName != "refs/heads/main" {
+		t.Fatalf("unexpected pull requests: %+v", fake.pulls)
+	}
+	if err := a.CreatePullRequest(context.Background()); err != ErrPullRequestAlreadyExists {
+		t.Errorf("want %v, got %v", ErrPullRequestAlreadyExists, err)
+	}
+}
+
+func TestAzureDevOpsParseURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		collection string
+		project    string
+		repo       string
+	}{
+		{"https://dev.azure.com/org/project/_git/repo", "https://dev.azure.com/org", "project", "repo"},
+		{"https://org@dev.azure.com/org/project/_git/repo", "https://dev.azure.com/org", "project", "repo"},
+		{"https://org.visualstudio.com/DefaultCollection/project/_git/repo", "https://org.visualstudio.com/DefaultCollection", "project", "repo"},
+		{"git@ssh.dev.azure.com:v3/org/project/repo", "https://dev.azure.com/org", "project", "repo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			collection, project, repo, err := (&AzureDevOpsRepository{GitSettings: GitSettings{URL: tt.url}}).parseURL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if collection != tt.collection || project != tt.project || repo != tt.repo {
+				t.Errorf("want %s %s %s, got %s %s %s", tt.collection, tt.project, tt.repo, collection, project, repo)
+			}
+		})
+	}
+}
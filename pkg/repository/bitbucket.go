@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// BitbucketServerRepository opens pull requests on Bitbucket Server or
+// Bitbucket Data Center. URL is the clone url of the repository, such as
+// https://bitbucket.example.com/scm/PROJ/repo.git.
+type BitbucketServerRepository struct {
+	GitSettings
+
+	Auth BitbucketAuth `json:"-"`
+
+	// APIURL is the REST API endpoint, by default `/rest/api/1.0` next to
+	// the `/scm` path of URL.
+	APIURL string `json:"apiURL,omitempty"`
+}
+
+// BitbucketAuth holds a personal or HTTP access token. Git requests
+// authenticate as User when it is given, with a bearer token otherwise.
+type BitbucketAuth struct {
+	User  string
+	Token string
+}
+
+func NewBitbucketServerRepository(git GitSettings, apiURL string, auth BitbucketAuth) *BitbucketServerRepository {
+	return &BitbucketServerRepository{
+		GitSettings: git,
+		Auth:        auth,
+		APIURL:      apiURL,
+	}
+}
+
+// PushReplaceTagCommit rewrites the references of image below Path and
+// pushes the result to the Head branch, or to Base when Push is set.
+func (b *BitbucketServerRepository) PushReplaceTagCommit(ctx context.Context, image, tag, digest string) error {
+	endpoint, err := transport.NewEndpoint(b.URL)
+	if err != nil {
+		return err
+	}
+	var auth transport.AuthMethod
+	if strings.HasPrefix(endpoint.Protocol, "http") && b.Auth.Token != "" {
+		if b.Auth.User != "" {
+			auth = &githttp.BasicAuth{Username: b.Auth.User, Password: b.Auth.Token}
+		} else {
+			auth = &githttp.TokenAuth{Token: b.Auth.Token}
+		}
+	}
+	return b.remote(auth).pushReplaceTagCommit(ctx, image, tag, digest)
+}
+
+type bitbucketProject struct {
+	Key string `json:"key"`
+}
+
+type bitbucketRepository struct {
+	Slug    string           `json:"slug"`
+	Project bitbucketProject `json:"project"`
+}
+
+type bitbucketRef struct {
+	ID         string              `json:"id"`
+	Repository bitbucketRepository `json:"repository"`
+}
+
+type bitbucketPullRequest struct {
+	ID          int          `json:"id,omitempty"`
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	FromRef     bitbucketRef `json:"fromRef"`
+	ToRef       bitbucketRef `json:"toRef"`
+}
+
+type bitbucketPullRequestPage struct {
+	Values        []bitbucketPullRequest `json:"values"`
+	IsLastPage    bool                   `json:"isLastPage"`
+	NextPageStart int                    `json:"nextPageStart"`
+}
+
+func (b *BitbucketServerRepository) CreatePullRequest(ctx context.Context) error {
+	project, slug, err := ownerAndRepository(b.URL)
+	if err != nil {
+		return err
+	}
+	api, err := b.apiURL()
+	if err != nil {
+		return err
+	}
+	pulls := fmt.Sprintf("%s/projects/%s/repos/%s/pull-requests", api, url.PathEscape(project), url.PathEscape(slug))
+
+	head := "refs/heads/" + b.Head
+	base := "refs/heads/" + b.Base
+	query := url.Values{}
+	query.Set("state", "OPEN")
+	query.Set("direction", "OUTGOING")
+	query.Set("at", head)
+	for start := 0; ; {
+		query.Set("start", fmt.Sprint(start))
+		var page bitbucketPullRequestPage
+		if err := b.do(ctx, http.MethodGet, pulls+"?"+query.Encode(), nil, &page); err != nil {
+			return err
+		}
+		for _, pr := range page.Values {
+			if pr.FromRef.ID == head && pr.ToRef.ID == base {
+				return ErrPullRequestAlreadyExists
+			}
+		}
+		if page.IsLastPage || len(page.Values) == 0 {
+			break
+		}
+		start = page.NextPageStart
+	}
+
+	repo := bitbucketRepository{Slug: slug, Project: bitbucketProject{Key: project}}
+	return b.do(ctx, http.MethodPost, pulls, &bitbucketPullRequest{
+		Title:   "Automaticaly update image tags",
+		FromRef: bitbucketRef{ID: head, Repository: repo},
+		ToRef:   bitbucketRef{ID: base, Repository: repo},
+	}, nil)
+}
+
+func (b *BitbucketServerRepository) do(ctx context.Context, method, u string, in, out interface{}) error {
+	return doJSON(ctx, method, u, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+b.Auth.Token)
+	}, in, out)
+}
+
+func (b *BitbucketServerRepository) apiURL() (string, error) {
+	if b.APIURL != "" {
+		return strings.TrimSuffix(b.APIURL, "/"), nil
+	}
+	endpoint, err := transport.NewEndpoint(b.URL)
+	if err != nil {
+		return "", err
+	}
+	// Instances served below a context path clone from <context>/scm/.
+	var contextPath string
+	if i := strings.Index(endpoint.Path, "/scm/"); i >= 0 && strings.HasPrefix(endpoint.Protocol, "http") {
+		contextPath = endpoint.Path[:i]
+	}
+	return apiURL(b.URL, contextPath+"/rest/api/1.0")
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeBitbucketServer serves the pull request endpoints of the Bitbucket
+// Server API for the repository PROJ/repo, below the context path /bitbucket.
+type fakeBitbucketServer struct {
+	token string
+	pulls []bitbucketPullRequest
+}
+
+func (f *fakeBitbucketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		http.Error(w, `{"errors":[{"message":"Authentication failed"}]}`, http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != "/bitbucket/rest/api/1.0/projects/PROJ/repos/repo/pull-requests" {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		// Serve one pull request per page.
+		var start int
+		json.Unmarshal([]byte(r.URL.Query().Get("start")), &start)
+		page := bitbucketPullRequestPage{IsLastPage: start+1 >= len(f.pulls), NextPageStart: start + 1}
+		if start < len(f.pulls) {
+			page.Values = f.pulls[start : start+1]
+		}
+		json.NewEncoder(w).Encode(page)
+	case http.MethodPost:
+		var pr bitbucketPullRequest
+		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if pr.FromRef.Repository.Slug != "repo" || pr.ToRef.Repository.Project.Key != "PROJ" {
+			http.Error(w, "unknown repository", http.StatusBadRequest)
+			return
+		}
+		pr.ID = len(f.pulls) + 1
+		f.pulls = append(f.pulls, pr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pr)
+	}
+}
+
+func TestBitbucketServerCreatePullRequest(t *testing.T) {
+	fake := &fakeBitbucketServer{
+		token: "secret",
+		pulls: []bitbucketPullRequest{
+			{ID: 1, FromRef: bitbucketRef{ID: "refs/heads/" + DefaultHead}, ToRef: bitbucketRef{ID: "refs/heads/release"}},
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	b := NewBitbucketServerRepository(NewGitSettings(server.URL+"/bitbucket/scm/PROJ/repo.git", "main", "", ""), "", BitbucketAuth{Token: "secret"})
+	if err := b.CreatePullRequest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.pulls) != 2 || fake.pulls[1].FromRef.ID != "refs/heads/"+DefaultHead || fake.pulls[1].ToRef.ID != "refs/heads/main" {
+		t.Fatalf("unexpected pull requests: %+v", fake.pulls)
+	}
+	if err := b.CreatePullRequest(context.Background()); err != ErrPullRequestAlreadyExists {
+		t.Errorf("want %v, got %v", ErrPullRequestAlreadyExists, err)
+	}
+}
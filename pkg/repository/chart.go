@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChartReplacer rewrites the version of a Helm chart referenced from YAML
+// files. It updates the sibling `version` or `targetRevision` of mappings
+// naming the chart in `chart`, as in Flux HelmReleases, Argo CD
+// Applications and helmfiles, and the `version` of dependencies naming the
+// chart in Chart.yaml. Other files are left untouched.
+//
+// When URL is set, references whose repository is known to be another one
+// are left alone too. The repository is the sibling `repoURL` of Argo CD
+// Applications, the `repository` of Chart.yaml dependencies, and the url
+// of the HelmRepository named by `sourceRef` or of the helmfile repository
+// prefixing the chart, when declared in the same file.
+type ChartReplacer struct {
+	URL string
+}
+
+func (c ChartReplacer) Replace(path string, content []byte, chart, version, digest string) ([]byte, error) {
+	if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+		return content, nil
+	}
+	docs, err := decodeYAML(content)
+	if err != nil {
+		// Not every file below Path has to be YAML, e.g. templates.
+		return content, nil
+	}
+
+	repos := chartRepositories(docs)
+	var edits []scalarEdit
+	for _, doc := range docs {
+		walkMappings(doc, func(m *yaml.Node) {
+			target := c.chartVersionNode(m, chart, repos)
+			if target != nil && target.Kind == yaml.ScalarNode {
+				edits = append(edits, scalarEdit{node: target, value: version})
+			}
+		})
+	}
+	return applyEdits(content, edits), nil
+}
+
+// chartVersionNode returns the node holding the version of chart in the
+// mapping m, if m references chart. repos holds the urls of the Helm
+// repositories declared in the file by name.
+func (c ChartReplacer) chartVersionNode(m *yaml.Node, chart string, repos map[string]string) *yaml.Node {
+	if ref := mappingValue(m, "chart"); ref != nil && matchChart(ref.Value, chart) {
+		if !c.matchURL(chartURL(m, ref.Value, repos)) {
+			return nil
+		}
+		if v := mappingValue(m, "version"); v != nil {
+			return v
+		}
+		return mappingValue(m, "targetRevision")
+	}
+	// Chart.yaml dependencies.
+	if ref := mappingValue(m, "name"); ref != nil && ref.Value == chart {
+		repo := mappingValue(m, "repository")
+		if repo == nil {
+			return nil
+		}
+		// Aliases of repositories added with `helm repo add` cannot be
+		// resolved here.
+		if !strings.HasPrefix(repo.Value, "@") && !strings.HasPrefix(repo.Value, "alias:") && !c.matchURL(repo.Value) {
+			return nil
+		}
+		return mappingValue(m, "version")
+	}
+	return nil
+}
+
+// chartURL returns the url of the repository the chart reference ref in
+// the mapping m points to, or "" when unknown.
+func chartURL(m *yaml.Node, ref string, repos map[string]string) string {
+	if u := mappingValue(m, "repoURL"); u != nil {
+		return u.Value
+	}
+	if source := mappingValue(m, "sourceRef"); source != nil {
+		if name := mappingValue(source, "name"); name != nil {
+			return repos[name.Value]
+		}
+		return ""
+	}
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		return repos[ref[:i]]
+	}
+	return ""
+}
+
+// chartRepositories returns the urls of the Flux HelmRepositories and of
+// the helmfile repositories declared in docs by name.
+func chartRepositories(docs []*yaml.Node) map[string]string {
+	repos := map[string]string{}
+	for _, doc := range docs {
+		walkMappings(doc, func(m *yaml.Node) {
+			if kind := mappingValue(m, "kind"); kind != nil && kind.Value == "HelmRepository" {
+				name := mappingValue(mappingValue(m, "metadata"), "name")
+				u := mappingValue(mappingValue(m, "spec"), "url")
+				if name != nil && u != nil {
+					repos[name.Value] = u.Value
+				}
+			}
+			if list := mappingValue(m, "repositories"); list != nil && list.Kind == yaml.SequenceNode {
+				for _, r := range list.Content {
+					name, u := mappingValue(r, "name"), mappingValue(r, "url")
+					if name != nil && u != nil {
+						repos[name.Value] = u.Value
+					}
+				}
+			}
+		})
+	}
+	return repos
+}
+
+// matchURL reports whether the repository url u is URL. Unknown
+// repositories match.
+func (c ChartReplacer) matchURL(u string) bool {
+	if c.URL == "" || u == "" {
+		return true
+	}
+	return normalizeChartURL(u) == normalizeChartURL(c.URL)
+}
+
+// normalizeChartURL strips the trailing slash and the oci scheme, which
+// Argo CD leaves out of the repoURL of OCI repositories.
+func normalizeChartURL(u string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(u, "oci://"), "/")
+}
+
+// matchChart reports whether ref, either a bare chart name or one
+// qualified by its repository like `bitnami/nginx`, names chart.
+func matchChart(ref, chart string) bool {
+	return ref == chart || strings.HasSuffix(ref, "/"+chart)
+}
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"testing"
+)
+
+func TestChartReplacer(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		url     string
+		content string
+		want    string
+	}{
+		{
+			name: "helm release",
+			path: "release.yaml",
+			content: `apiVersion: helm.toolkit.fluxcd.io/v2beta1
+kind: HelmRelease
+spec:
+  chart:
+    spec:
+      chart: podinfo # pinned
+      version: "6.0.0" # bumped by manifest-updater
+      sourceRef:
+        kind: HelmRepository
+---
+spec:
+  chart:
+    spec:
+      chart: other
+      version: 6.0.0
+`,
+			want: `apiVersion: helm.toolkit.fluxcd.io/v2beta1
+kind: HelmRelease
+spec:
+  chart:
+    spec:
+      chart: podinfo # pinned
+      version: "6.1.0" # bumped by manifest-updater
+      sourceRef:
+        kind: HelmRepository
+---
+spec:
+  chart:
+    spec:
+      chart: other
+      version: 6.0.0
+`,
+		},
+		{
+			name: "argo application",
+			path: "app.yml",
+			content: `spec:
+  source:
+    repoURL: https://stefanprodan.github.io/podinfo
+    chart: podinfo
+    targetRevision: 6.0.0
+`,
+			want: `spec:
+  source:
+    repoURL: https://stefanprodan.github.io/podinfo
+    chart: podinfo
+    targetRevision: 6.1.0
+`,
+		},
+		{
+			name: "chart dependencies",
+			path: "Chart.yaml",
+			content: `name: app
+version: 1.0.0
+dependencies:
+- name: podinfo
+  version: '6.0.0'
+  repository: https://stefanprodan.github.io/podinfo
+`,
+			want: `name: app
+version: 1.0.0
+dependencies:
+- name: podinfo
+  version: '6.1.0'
+  repository: https://stefanprodan.github.io/podinfo
+`,
+		},
+		{
+			name: "argo application of another repository",
+			path: "app.yaml",
+			url:  "https://stefanprodan.github.io/podinfo/",
+			content: `spec:
+  source:
+    repoURL: https://charts.example.com
+    chart: podinfo
+    targetRevision: 6.0.0
+---
+spec:
+  source:
+    repoURL: https://stefanprodan.github.io/podinfo
+    chart: podinfo
+    targetRevision: 6.0.0
+`,
+			want: `spec:
+  source:
+    repoURL: https://charts.example.com
+    chart: podinfo
+    targetRevision: 6.0.0
+---
+spec:
+  source:
+    repoURL: https://stefanprodan.github.io/podinfo
+    chart: podinfo
+    targetRevision: 6.1.0
+`,
+		},
+		{
+			name: "argo application of an oci repository",
+			path: "app.yaml",
+			url:  "oci://ghcr.io/stefanprodan/charts",
+			content: `spec:
+  source:
+    repoURL: ghcr.io/stefanprodan/charts
+    chart: podinfo
+    targetRevision: 6.0.0
+`,
+			want: `spec:
+  source:
+    repoURL: ghcr.io/stefanprodan/charts
+    chart: podinfo
+    targetRevision: 6.1.0
+`,
+		},
+		{
+			name: "chart dependencies of another repository",
+			path: "Chart.yaml",
+			url:  "https://stefanprodan.github.io/podinfo",
+			content: `dependencies:
+- name: podinfo
+  version: 6.0.0
+  repository: https://charts.example.com
+- name: podinfo
+  version: 6.0.0
+  repository: "@podinfo"
+`,
+			want: `dependencies:
+- name: podinfo
+  version: 6.0.0
+  repository: https://charts.example.com
+- name: podinfo
+  version: 6.1.0
+  repository: "@podinfo"
+`,
+		},
+		{
+			name: "helm releases by source",
+			path: "release.yaml",
+			url:  "https://stefanprodan.github.io/podinfo",
+			content: `kind: HelmRepository
+metadata:
+  name: podinfo
+spec:
+  url: https://stefanprodan.github.io/podinfo
+---
+kind: HelmRepository
+metadata:
+  name: mirror
+spec:
+  url: https://charts.example.com
+---
+kind: HelmRelease
+spec:
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.0.0
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+---
+kind: HelmRelease
+spec:
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.0.0
+      sourceRef:
+        kind: HelmRepository
+        name: mirror
+`,
+			want: `kind: HelmRepository
+metadata:
+  name: podinfo
+spec:
+  url: https://stefanprodan.github.io/podinfo
+---
+kind: HelmRepository
+metadata:
+  name: mirror
+spec:
+  url: https://charts.example.com
+---
+kind: HelmRelease
+spec:
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.1.0
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+---
+kind: HelmRelease
+spec:
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.0.0
+      sourceRef:
+        kind: HelmRepository
+        name: mirror
+`,
+		},
+		{
+			name: "helmfile repositories",
+			path: "helmfile.yaml",
+			url:  "https://stefanprodan.github.io/podinfo",
+			content: `repositories:
+- name: podinfo
+  url: https://stefanprodan.github.io/podinfo
+- name: mirror
+  url: https://charts.example.com
+releases:
+- name: app
+  chart: podinfo/podinfo
+  version: 6.0.0
+- name: copy
+  chart: mirror/podinfo
+  version: 6.0.0
+`,
+			want: `repositories:
+- name: podinfo
+  url: https://stefanprodan.github.io/podinfo
+- name: mirror
+  url: https://charts.example.com
+releases:
+- name: app
+  chart: podinfo/podinfo
+  version: 6.1.0
+- name: copy
+  chart: mirror/podinfo
+  version: 6.0.0
+`,
+		},
+		{
+			name:    "not yaml",
+			path:    "README.md",
+			content: "chart: podinfo\nversion: 6.0.0\n",
+			want:    "chart: podinfo\nversion: 6.0.0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ChartReplacer{URL: tt.url}.Replace(tt.path, []byte(tt.content), "podinfo", "6.1.0", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestChartReplacerNumericVersions(t *testing.T) {
+	content := "spec:\n  chart: podinfo\n  version: 6.9\n"
+	want := "spec:\n  chart: podinfo\n  version: \"6.10\"\n"
+	got, err := ChartReplacer{}.Replace("release.yaml", []byte(content), "podinfo", "6.10", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,316 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+var nowFunc = time.Now
+
+var (
+	DefaultHead     = "feature/update-tag"
+	DefaultCloneDir = "/tmp"
+
+	// DefaultPushAttempts is how many times a direct push is retried on
+	// top of the latest Base when it is rejected.
+	DefaultPushAttempts = 3
+)
+
+// GitSettings locates the manifests in a git repository and tells how the
+// updates are committed. The hosting backends embed it.
+type GitSettings struct {
+	URL  string `json:"url"`
+	Base string `json:"base"`
+	Head string `json:"head"`
+	Path string `json:"path,omitempty"`
+
+	// Replacer rewrites the files below Path. It defaults to ImageReplacer.
+	Replacer Replacer `json:"-"`
+	// Push commits to Base directly instead of Head.
+	Push bool `json:"push,omitempty"`
+}
+
+// NewGitSettings returns the GitSettings of the repository url, defaulting
+// base to master, head to DefaultHead and path to the repository root.
+func NewGitSettings(url, base, head, path string) GitSettings {
+	if base == "" {
+		base = "master"
+	}
+	if head == "" {
+		head = DefaultHead
+	}
+	if path == "" {
+		path = "/"
+	}
+	return GitSettings{
+		URL:  url,
+		Base: base,
+		Head: head,
+		Path: path,
+
+		Replacer: ImageReplacer{},
+	}
+}
+
+// remote returns the gitRemote authenticating with auth.
+func (s *GitSettings) remote(auth transport.AuthMethod) *gitRemote {
+	return &gitRemote{GitSettings: *s, Auth: auth}
+}
+
+// gitRemote is the git side shared by the hosting backends: it clones the
+// repository, rewrites the files below Path and pushes them to Head. When
+// Push is set the commit is pushed to Base directly.
+type gitRemote struct {
+	GitSettings
+
+	Auth transport.AuthMethod
+}
+
+func (r *gitRemote) pushReplaceTagCommit(ctx context.Context, image, tag, digest string) error {
+	endpoint, err := transport.NewEndpoint(r.URL)
+	if err != nil {
+		return err
+	}
+	clonepath := filepath.Join(
+		DefaultCloneDir,
+		endpoint.Host,
+		strings.TrimSuffix(strings.TrimPrefix(endpoint.Path, "/"), ".git"),
+	)
+
+	branch := plumbing.NewBranchReferenceName(r.Base)
+
+	var repository *git.Repository
+	if _, err := os.Stat(clonepath); os.IsNotExist(err) {
+		opts := &git.CloneOptions{
+			URL:           r.URL,
+			Auth:          r.Auth,
+			SingleBranch:  true,
+			ReferenceName: branch,
+		}
+		repository, err = git.PlainCloneContext(ctx, clonepath, false, opts)
+		if err != nil {
+			return err
+		}
+	} else {
+		repository, err = git.PlainOpen(clonepath)
+		if err != nil {
+			return err
+		}
+	}
+
+	worktree, err := repository.Worktree()
+	if err != nil {
+		return err
+	}
+	if r.Push {
+		return r.pushDirect(ctx, repository, worktree, clonepath, image, tag, digest)
+	}
+	err = worktree.PullContext(ctx, &git.PullOptions{
+		Auth:          r.Auth,
+		Force:         true,
+		SingleBranch:  true,
+		ReferenceName: branch,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+
+	defer func() {
+		worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.Master})
+		repository.Storer.RemoveReference(plumbing.NewBranchReferenceName(r.Head))
+	}()
+
+	checkoutOpts := &git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(r.Head),
+	}
+	if _, err = repository.Branch(r.Head); errors.Is(err, git.ErrBranchNotFound) {
+		checkoutOpts.Create = true
+	}
+	if err := worktree.Checkout(checkoutOpts); err != nil {
+		return err
+	}
+
+	if err := r.replaceFiles(worktree, clonepath, image, tag, digest); err != nil {
+		return err
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return err
+	}
+
+	// To prevent non-fast-forward error, do not commit and push
+	// if no file was modified.
+	if len(status) == 0 {
+		return ErrTagNotReplaced
+	}
+
+	msg := "Update image tag names"
+	if _, err := worktree.Commit(msg, &git.CommitOptions{
+		All: true,
+		Author: &object.Signature{
+			Name: "manifest-updater",
+			When: nowFunc(),
+		},
+	}); err != nil {
+		return err
+	}
+
+	err = repository.PushContext(ctx, &git.PushOptions{Auth: r.Auth})
+	if err != nil {
+		if errors.Is(err, git.ErrNonFastForwardUpdate) {
+			return ErrTagAlreadyUpToDate
+		}
+
+		// Because of:
+		// https://github.com/src-d/go-git/blob/d6c4b113c17a011530e93f179b7ac27eb3f17b9b/remote.go#L784
+		// https://github.com/src-d/go-git/blob/d6c4b113c17a011530e93f179b7ac27eb3f17b9b/remote.go#L793
+		if strings.Contains(err.Error(), git.ErrNonFastForwardUpdate.Error()) {
+			return nil
+		}
+	}
+	return err
+}
+
+// pushDirect commits the rewritten files on top of Base and pushes them
+// to Base. A rejected push, because Base moved in the meantime, is retried
+// on top of the fetched Base up to DefaultPushAttempts times.
+func (r *gitRemote) pushDirect(ctx context.Context, repository *git.Repository, worktree *git.Worktree, clonepath, image, tag, digest string) error {
+	branch := plumbing.NewBranchReferenceName(r.Base)
+	refspec := config.RefSpec(fmt.Sprintf("+%s:%s", branch, plumbing.NewRemoteReferenceName(git.DefaultRemoteName, r.Base)))
+	for attempt := 1; ; attempt++ {
+		err := repository.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: []config.RefSpec{refspec},
+			Auth:     r.Auth,
+			Force:    true,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return err
+		}
+		ref, err := repository.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, r.Base), true)
+		if err != nil {
+			return err
+		}
+		if err := worktree.Checkout(&git.CheckoutOptions{Branch: branch, Force: true}); err != nil {
+			return err
+		}
+		if err := worktree.Reset(&git.ResetOptions{Commit: ref.Hash(), Mode: git.HardReset}); err != nil {
+			return err
+		}
+
+		if err := r.replaceFiles(worktree, clonepath, image, tag, digest); err != nil {
+			return err
+		}
+		status, err := worktree.Status()
+		if err != nil {
+			return err
+		}
+		if len(status) == 0 {
+			return ErrTagNotReplaced
+		}
+		if _, err := worktree.Commit("Update image tag names", &git.CommitOptions{
+			All: true,
+			Author: &object.Signature{
+				Name: "manifest-updater",
+				When: nowFunc(),
+			},
+		}); err != nil {
+			return err
+		}
+
+		err = repository.PushContext(ctx, &git.PushOptions{
+			RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", branch, branch))},
+			Auth:     r.Auth,
+		})
+		if err == nil || attempt >= DefaultPushAttempts {
+			return err
+		}
+		if !errors.Is(err, git.ErrNonFastForwardUpdate) && !strings.Contains(err.Error(), "non-fast-forward") {
+			return err
+		}
+	}
+}
+
+// replaceFiles rewrites the files below Path in the worktree and stages
+// the modified ones.
+func (r *gitRemote) replaceFiles(worktree *git.Worktree, clonepath, image, tag, digest string) error {
+	root := filepath.Join(clonepath, r.Path)
+	return filepath.Walk(
+		root,
+		func(path string, info os.FileInfo, err error) error {
+			if info.IsDir() {
+				return nil
+			}
+			if strings.HasPrefix(path, filepath.Join(clonepath, ".git")) {
+				return nil
+			}
+
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			replacedContent, err := r.Replacer.Replace(rel, content, image, tag, digest)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(path, replacedContent, 0); err != nil {
+				return err
+			}
+			if !bytes.Equal(content, replacedContent) {
+				prefix := fmt.Sprintf("%s/", clonepath)
+				if _, err := worktree.Add(strings.TrimPrefix(path, prefix)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+}
+
+// apiURL returns the url of the REST API served at path on the host of the
+// repository url u. Repositories cloned over ssh talk to the API over https.
+func apiURL(u, path string) (string, error) {
+	endpoint, err := transport.NewEndpoint(u)
+	if err != nil {
+		return "", err
+	}
+	if endpoint.Protocol != "http" && endpoint.Protocol != "https" {
+		return fmt.Sprintf("https://%s%s", endpoint.Host, path), nil
+	}
+	host := endpoint.Host
+	if endpoint.Port != 0 {
+		host = fmt.Sprintf("%s:%d", host, endpoint.Port)
+	}
+	return fmt.Sprintf("%s://%s%s", endpoint.Protocol, host, path), nil
+}
+
+// ownerAndRepository returns the owner and the name of the repository
+// from the last two elements of the path of its url u, such as
+// https://example.com/owner/repo.git.
+func ownerAndRepository(u string) (string, string, error) {
+	endpoint, err := transport.NewEndpoint(u)
+	if err != nil {
+		return "", "", err
+	}
+	path := strings.Split(strings.TrimSuffix(strings.Trim(endpoint.Path, "/"), ".git"), "/")
+	if len(path) < 2 {
+		return "", "", fmt.Errorf("%w: %s", ErrInvalidRepositoryURL, u)
+	}
+	return path[len(path)-2], path[len(path)-1], nil
+}
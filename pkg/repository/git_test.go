@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newOrigin returns the path of a repository whose master branch holds
+// files, and points DefaultCloneDir to a temporary directory.
+func newOrigin(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "manifest-updater")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cloneDir := DefaultCloneDir
+	DefaultCloneDir = filepath.Join(dir, "clones")
+	t.Cleanup(func() { DefaultCloneDir = cloneDir })
+
+	origin := filepath.Join(dir, "origin")
+	repo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(origin, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := worktree.Commit("Initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", When: time.Now()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return origin
+}
+
+// readBranchFile returns the content of the file name on branch of the
+// repository at path.
+func readBranchFile(t *testing.T, path, branch, name string) string {
+	t.Helper()
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := commit.File(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := file.Contents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestGitRemotePushReplaceTagCommit(t *testing.T) {
+	origin := newOrigin(t, map[string]string{
+		"app/deployment.yaml": "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v1\n",
+		"README.md":           "acme/app:v1\n",
+	})
+
+	remote := &gitRemote{GitSettings: GitSettings{
+		URL:      origin,
+		Base:     "master",
+		Head:     DefaultHead,
+		Path:     "/app",
+		Replacer: ImageReplacer{},
+	}}
+	if err := remote.pushReplaceTagCommit(context.Background(), "acme/app", "v2", ""); err != nil {
+		t.Fatal(err)
+	}
+	want := "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v2\n"
+	if got := readBranchFile(t, origin, DefaultHead, "app/deployment.yaml"); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+	if got := readBranchFile(t, origin, DefaultHead, "README.md"); got != "acme/app:v1\n" {
+		t.Errorf("README.md outside of Path was rewritten: %s", got)
+	}
+}
+
+func TestGitRemotePushDirect(t *testing.T) {
+	origin := newOrigin(t, map[string]string{
+		"deployment.yaml": "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v1\n",
+		"README.md":       "v1\n",
+	})
+
+	remote := &gitRemote{GitSettings: GitSettings{
+		URL:      origin,
+		Base:     "master",
+		Head:     DefaultHead,
+		Path:     "/",
+		Replacer: ImageReplacer{},
+		Push:     true,
+	}}
+	if err := remote.pushReplaceTagCommit(context.Background(), "acme/app", "v2", ""); err != nil {
+		t.Fatal(err)
+	}
+	want := "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v2\n"
+	if got := readBranchFile(t, origin, "master", "deployment.yaml"); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+
+	// Base moves on after the clone, the next push lands on top of it.
+	commitFile(t, origin, "README.md", "v2\n")
+
+	if err := remote.pushReplaceTagCommit(context.Background(), "acme/app", "v3", ""); err != nil {
+		t.Fatal(err)
+	}
+	want = "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v3\n"
+	if got := readBranchFile(t, origin, "master", "deployment.yaml"); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+	if got := readBranchFile(t, origin, "master", "README.md"); got != "v2\n" {
+		t.Errorf("the commit on base was lost: %s", got)
+	}
+	if err := remote.pushReplaceTagCommit(context.Background(), "acme/app", "v3", ""); err != ErrTagNotReplaced {
+		t.Errorf("want %v, got %v", ErrTagNotReplaced, err)
+	}
+	repo, err := git.PlainOpen(origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Reference(plumbing.NewBranchReferenceName(DefaultHead), true); err == nil {
+		t.Errorf("a direct push created %s", DefaultHead)
+	}
+}
+
+// racingReplacer commits to the origin while the first replacement is
+// under way, so that the push following it is rejected.
+type racingReplacer struct {
+	t      *testing.T
+	origin string
+	calls  int
+}
+
+func (r *racingReplacer) Replace(path string, content []byte, image, tag, digest string) ([]byte, error) {
+	r.calls++
+	if r.calls == 1 {
+		commitFile(r.t, r.origin, "README.md", "v2\n")
+	}
+	return ImageReplacer{}.Replace(path, content, image, tag, digest)
+}
+
+// commitFile commits content to the file name on the checked out branch of
+// the repository at path.
+func commitFile(t *testing.T, path, name, content string) {
+	t.Helper()
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := worktree.Reset(&git.ResetOptions{Mode: git.HardReset}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Commit("Update "+name, &git.CommitOptions{
+		All:    true,
+		Author: &object.Signature{Name: "test", When: time.Now()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitRemotePushDirectRetry(t *testing.T) {
+	origin := newOrigin(t, map[string]string{
+		"deployment.yaml": "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v1\n",
+		"README.md":       "v1\n",
+	})
+
+	replacer := &racingReplacer{t: t, origin: origin}
+	remote := &gitRemote{GitSettings: GitSettings{
+		URL:      origin,
+		Base:     "master",
+		Head:     DefaultHead,
+		Path:     "/",
+		Replacer: replacer,
+		Push:     true,
+	}}
+	if err := remote.pushReplaceTagCommit(context.Background(), "acme/app", "v2", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Both files are replaced once per attempt.
+	if replacer.calls != 4 {
+		t.Errorf("want 2 attempts, got %d replacements", replacer.calls)
+	}
+	want := "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v2\n"
+	if got := readBranchFile(t, origin, "master", "deployment.yaml"); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+	if got := readBranchFile(t, origin, "master", "README.md"); got != "v2\n" {
+		t.Errorf("the commit on base was lost: %s", got)
+	}
+}
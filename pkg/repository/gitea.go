@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// giteaPageSize is the number of pull requests listed per request.
+const giteaPageSize = 50
+
+// GiteaRepository opens pull requests on a Gitea or Forgejo instance.
+type GiteaRepository struct {
+	GitSettings
+
+	Auth GiteaAuth `json:"-"`
+
+	// APIURL is the REST API endpoint, by default `/api/v1` on the host
+	// of URL.
+	APIURL string `json:"apiURL,omitempty"`
+}
+
+type GiteaAuth struct {
+	Token string
+}
+
+func NewGiteaRepository(git GitSettings, apiURL string, auth GiteaAuth) *GiteaRepository {
+	return &GiteaRepository{
+		GitSettings: git,
+		Auth:        auth,
+		APIURL:      apiURL,
+	}
+}
+
+// PushReplaceTagCommit rewrites the references of image below Path and
+// pushes the result to the Head branch, or to Base when Push is set.
+func (g *GiteaRepository) PushReplaceTagCommit(ctx context.Context, image, tag, digest string) error {
+	endpoint, err := transport.NewEndpoint(g.URL)
+	if err != nil {
+		return err
+	}
+	var auth transport.AuthMethod
+	if strings.HasPrefix(endpoint.Protocol, "http") && g.Auth.Token != "" {
+		// Gitea takes the token as user name, without password.
+		auth = &githttp.BasicAuth{Username: g.Auth.Token}
+	}
+	return g.remote(auth).pushReplaceTagCommit(ctx, image, tag, digest)
+}
+
+type giteaBranch struct {
+	Ref string `json:"ref"`
+}
+
+type giteaPullRequest struct {
+	Number int         `json:"number"`
+	Head   giteaBranch `json:"head"`
+	Base   giteaBranch `json:"base"`
+}
+
+type giteaNewPullRequest struct {
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+func (g *GiteaRepository) CreatePullRequest(ctx context.Context) error {
+	owner, repo, err := ownerAndRepository(g.URL)
+	if err != nil {
+		return err
+	}
+	api, err := g.apiURL()
+	if err != nil {
+		return err
+	}
+	pulls := fmt.Sprintf("%s/repos/%s/%s/pulls", api, owner, repo)
+
+	for page := 1; ; page++ {
+		var prs []giteaPullRequest
+		u := fmt.Sprintf("%s?state=open&page=%d&limit=%d", pulls, page, giteaPageSize)
+		if err := g.do(ctx, http.MethodGet, u, nil, &prs); err != nil {
+			return err
+		}
+		for _, pr := range prs {
+			if pr.Head.Ref == g.Head && pr.Base.Ref == g.Base {
+				return ErrPullRequestAlreadyExists
+			}
+		}
+		if len(prs) < giteaPageSize {
+			break
+		}
+	}
+
+	return g.do(ctx, http.MethodPost, pulls, &giteaNewPullRequest{
+		Head:  g.Head,
+		Base:  g.Base,
+		Title: "Automaticaly update image tags",
+	}, nil)
+}
+
+func (g *GiteaRepository) do(ctx context.Context, method, u string, in, out interface{}) error {
+	return doJSON(ctx, method, u, func(req *http.Request) {
+		req.Header.Set("Authorization", "token "+g.Auth.Token)
+	}, in, out)
+}
+
+func (g *GiteaRepository) apiURL() (string, error) {
+	if g.APIURL != "" {
+		return strings.TrimSuffix(g.APIURL, "/"), nil
+	}
+	return apiURL(g.URL, "/api/v1")
+}
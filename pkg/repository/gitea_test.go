@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeGitea serves the pull request endpoints of the Gitea API for the
+// repository owner/repo.
+type fakeGitea struct {
+	token string
+	pulls []giteaPullRequest
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "token "+f.token {
+		http.Error(w, `{"message":"token is required"}`, http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != "/api/v1/repos/owner/repo/pulls" {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get("page") != "1" {
+			json.NewEncoder(w).Encode([]giteaPullRequest{})
+			return
+		}
+		json.NewEncoder(w).Encode(append([]giteaPullRequest{}, f.pulls...))
+	case http.MethodPost:
+		var pr giteaNewPullRequest
+		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.pulls = append(f.pulls, giteaPullRequest{
+			Number: len(f.pulls) + 1,
+			Head:   giteaBranch{Ref: pr.Head},
+			Base:   giteaBranch{Ref: pr.Base},
+		})
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(f.pulls[len(f.pulls)-1])
+	}
+}
+
+func TestGiteaCreatePullRequest(t *testing.T) {
+	fake := &fakeGitea{
+		token: "secret",
+		pulls: []giteaPullRequest{{Number: 1, Head: giteaBranch{Ref: DefaultHead}, Base: giteaBranch{Ref: "release"}}},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	g := NewGiteaRepository(NewGitSettings(server.URL+"/owner/repo.git", "main", "", ""), "", GiteaAuth{Token: "secret"})
+	if err := g.CreatePullRequest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.pulls) != 2 || fake.pulls[1].Head.Ref != DefaultHead || fake.pulls[1].Base.Ref != "main" {
+		t.Fatalf("unexpected pull requests: %+v", fake.pulls)
+	}
+	if err := g.CreatePullRequest(context.Background()); err != ErrPullRequestAlreadyExists {
+		t.Errorf("want %v, got %v", ErrPullRequestAlreadyExists, err)
+	}
+}
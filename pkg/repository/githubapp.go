@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidPrivateKey = errors.New("invalid GitHub App private key")
+)
+
+// tokenRefreshMargin is how long before their expiry installation tokens
+// are refreshed, so that a token does not expire in the middle of a push.
+var tokenRefreshMargin = 5 * time.Minute
+
+// GitHubApp authenticates as a GitHub App. It signs JWTs with the private
+// key of the app and exchanges them for tokens of the installation on the
+// repository, which are cached until shortly before they expire.
+type GitHubApp struct {
+	ID         int64
+	PrivateKey *rsa.PrivateKey
+
+	// APIURL is the REST API endpoint, by default https://api.github.com/.
+	APIURL string
+
+	mu            sync.Mutex
+	installations map[string]int64
+	tokens        map[int64]installationToken
+}
+
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewGitHubApp returns the GitHub App id holding the PEM encoded PKCS#1 or
+// PKCS#8 RSA private key.
+func NewGitHubApp(id int64, privateKey []byte) (*GitHubApp, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		k, perr := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if perr != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPrivateKey, err)
+		}
+		var ok bool
+		if key, ok = k.(*rsa.PrivateKey); !ok {
+			return nil, ErrInvalidPrivateKey
+		}
+	}
+	return &GitHubApp{
+		ID:            id,
+		PrivateKey:    key,
+		installations: map[string]int64{},
+		tokens:        map[int64]installationToken{},
+	}, nil
+}
+
+// Token returns a token of the installation of the app on owner/repo.
+func (a *GitHubApp) Token(ctx context.Context, owner, repo string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id, ok := a.installations[owner+"/"+repo]
+	if !ok {
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+		if err := a.do(ctx, http.MethodGet, fmt.Sprintf("repos/%s/%s/installation", owner, repo), &installation); err != nil {
+			return "", err
+		}
+		id = installation.ID
+		a.installations[owner+"/"+repo] = id
+	}
+
+	if token, ok := a.tokens[id]; ok && nowFunc().Add(tokenRefreshMargin).Before(token.ExpiresAt) {
+		return token.Token, nil
+	}
+	var token installationToken
+	if err := a.do(ctx, http.MethodPost, fmt.Sprintf("app/installations/%d/access_tokens", id), &token); err != nil {
+		return "", err
+	}
+	a.tokens[id] = token
+	return token.Token, nil
+}
+
+func (a *GitHubApp) do(ctx context.Context, method, path string, out interface{}) error {
+	jwt, err := a.jwt()
+	if err != nil {
+		return err
+	}
+	api := a.APIURL
+	if api == "" {
+		api = "https://api.github.com/"
+	}
+	return doJSON(ctx, method, strings.TrimSuffix(api, "/")+"/"+path, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+jwt)
+	}, nil, out)
+}
+
+// jwt returns a JWT identifying the app, valid for 9 minutes. It is
+// backdated by a minute to allow for clock drift.
+func (a *GitHubApp) jwt() (string, error) {
+	now := nowFunc()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
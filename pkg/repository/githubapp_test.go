@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGitHubApps serves the installation endpoints of the GitHub API for
+// the app holding key, installed on acme/app with the id 42.
+type fakeGitHubApps struct {
+	key    *rsa.PublicKey
+	issued int
+}
+
+func (f *fakeGitHubApps) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+	if len(parts) != 3 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(f.key, crypto.SHA256, digest[:], signature); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/app/installation":
+		fmt.Fprint(w, `{"id":42}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/app/installations/42/access_tokens":
+		f.issued++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(installationToken{
+			Token:     fmt.Sprintf("token-%d", f.issued),
+			ExpiresAt: nowFunc().Add(time.Hour),
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestGitHubAppToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeGitHubApps{key: &key.PublicKey}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	now := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	app, err := NewGitHubApp(1, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.APIURL = server.URL
+
+	for _, tt := range []struct {
+		after time.Duration
+		want  string
+	}{
+		{0, "token-1"},
+		{30 * time.Minute, "token-1"},
+		// Refreshed 4 minutes before it expires.
+		{26 * time.Minute, "token-2"},
+	} {
+		now = now.Add(tt.after)
+		token, err := app.Token(context.Background(), "acme", "app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != tt.want {
+			t.Errorf("want %s, got %s", tt.want, token)
+		}
+	}
+	if _, err := app.Token(context.Background(), "acme", "other"); err == nil {
+		t.Error("want an error for a repository without installation")
+	}
+	if _, err := NewGitHubApp(1, []byte("not a key")); err != ErrInvalidPrivateKey {
+		t.Errorf("want %v, got %v", ErrInvalidPrivateKey, err)
+	}
+}
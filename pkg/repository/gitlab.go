@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// GitLabRepository opens merge requests on gitlab.com or a self-managed
+// GitLab instance.
+type GitLabRepository struct {
+	GitSettings
+
+	Auth GitLabAuth `json:"-"`
+
+	// APIURL is the REST API endpoint, by default `/api/v4` on the host
+	// of URL.
+	APIURL string `json:"apiURL,omitempty"`
+}
+
+type GitLabAuth struct {
+	Token string
+}
+
+func NewGitLabRepository(git GitSettings, apiURL string, auth GitLabAuth) *GitLabRepository {
+	return &GitLabRepository{
+		GitSettings: git,
+		Auth:        auth,
+		APIURL:      apiURL,
+	}
+}
+
+// PushReplaceTagCommit rewrites the references of image below Path and
+// pushes the result to the Head branch, or to Base when Push is set.
+func (g *GitLabRepository) PushReplaceTagCommit(ctx context.Context, image, tag, digest string) error {
+	endpoint, err := transport.NewEndpoint(g.URL)
+	if err != nil {
+		return err
+	}
+	var auth transport.AuthMethod
+	if strings.HasPrefix(endpoint.Protocol, "http") && g.Auth.Token != "" {
+		auth = &githttp.BasicAuth{Username: "oauth2", Password: g.Auth.Token}
+	}
+	return g.remote(auth).pushReplaceTagCommit(ctx, image, tag, digest)
+}
+
+type gitlabMergeRequest struct {
+	IID          int    `json:"iid,omitempty"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	Title        string `json:"title,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+func (g *GitLabRepository) CreatePullRequest(ctx context.Context) error {
+	project, err := g.projectPath()
+	if err != nil {
+		return err
+	}
+	api, err := g.apiURL()
+	if err != nil {
+		return err
+	}
+	base := fmt.Sprintf("%s/projects/%s/merge_requests", api, url.PathEscape(project))
+
+	query := url.Values{}
+	query.Set("state", "opened")
+	query.Set("source_branch", g.Head)
+	query.Set("target_branch", g.Base)
+	var mrs []gitlabMergeRequest
+	if err := g.do(ctx, http.MethodGet, base+"?"+query.Encode(), nil, &mrs); err != nil {
+		return err
+	}
+	if len(mrs) > 0 {
+		return ErrPullRequestAlreadyExists
+	}
+
+	return g.do(ctx, http.MethodPost, base, &gitlabMergeRequest{
+		SourceBranch: g.Head,
+		TargetBranch: g.Base,
+		Title:        "Automaticaly update image tags",
+	}, nil)
+}
+
+func (g *GitLabRepository) do(ctx context.Context, method, u string, in, out interface{}) error {
+	return doJSON(ctx, method, u, func(req *http.Request) {
+		req.Header.Set("PRIVATE-TOKEN", g.Auth.Token)
+	}, in, out)
+}
+
+func (g *GitLabRepository) apiURL() (string, error) {
+	if g.APIURL != "" {
+		return strings.TrimSuffix(g.APIURL, "/"), nil
+	}
+	return apiURL(g.URL, "/api/v4")
+}
+
+// projectPath returns the full path of the project, including its
+// subgroups, e.g. `group/subgroup/project`.
+func (g *GitLabRepository) projectPath() (string, error) {
+	endpoint, err := transport.NewEndpoint(g.URL)
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSuffix(strings.Trim(endpoint.Path, "/"), ".git")
+	if !strings.Contains(path, "/") {
+		return "", fmt.Errorf("%w: %s", ErrInvalidRepositoryURL, g.URL)
+	}
+	return path, nil
+}
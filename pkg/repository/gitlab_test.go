@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeGitLab serves the merge request endpoints of the GitLab API for the
+// project group/subgroup/project.
+type fakeGitLab struct {
+	token   string
+	created []gitlabMergeRequest
+	opened  []gitlabMergeRequest
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("PRIVATE-TOKEN") != f.token {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if r.URL.EscapedPath() != "/api/v4/projects/group%2Fsubgroup%2Fproject/merge_requests" {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		var mrs []gitlabMergeRequest
+		for _, mr := range f.opened {
+			if mr.SourceBranch == r.URL.Query().Get("source_branch") && mr.TargetBranch == r.URL.Query().Get("target_branch") {
+				mrs = append(mrs, mr)
+			}
+		}
+		json.NewEncoder(w).Encode(append([]gitlabMergeRequest{}, mrs...))
+	case http.MethodPost:
+		var mr gitlabMergeRequest
+		if err := json.NewDecoder(r.Body).Decode(&mr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mr.IID = len(f.created) + 1
+		f.created = append(f.created, mr)
+		f.opened = append(f.opened, mr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(mr)
+	}
+}
+
+func TestGitLabCreatePullRequest(t *testing.T) {
+	fake := &fakeGitLab{token: "secret"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	g := NewGitLabRepository(NewGitSettings(server.URL+"/group/subgroup/project.git", "main", "", ""), "", GitLabAuth{Token: "secret"})
+	if err := g.CreatePullRequest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.created) != 1 || fake.created[0].SourceBranch != DefaultHead || fake.created[0].TargetBranch != "main" {
+		t.Fatalf("unexpected merge requests: %+v", fake.created)
+	}
+	if err := g.CreatePullRequest(context.Background()); err != ErrPullRequestAlreadyExists {
+		t.Errorf("want %v, got %v", ErrPullRequestAlreadyExists, err)
+	}
+
+	g.Auth.Token = "wrong"
+	if err := g.CreatePullRequest(context.Background()); err == nil {
+		t.Error("want an error with a wrong token")
+	}
+}
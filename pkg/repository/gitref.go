@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GitRefReplacer rewrites references to a tag of a git repository, where
+// image is the repository such as `github.com/owner/repo`. It rewrites
+// release download, blob and tree urls, kustomize remote bases pinned with
+// `?ref=`, and raw.githubusercontent.com urls for repositories hosted on
+// github.com. Only refs containing a digit are rewritten, so that urls
+// pointing to branches such as `main` are left alone.
+type GitRefReplacer struct{}
+
+func (GitRefReplacer) Replace(path string, content []byte, image, tag, digest string) ([]byte, error) {
+	repo := regexp.QuoteMeta(strings.TrimSuffix(image, ".git"))
+	prefixes := []string{
+		repo + `(?:\.git)?(?:/releases/download/|/blob/|/tree/)`,
+		repo + `(?:\.git)?(?://[^\s?"']*)?\?(?:[^\s"']*&)?ref=`,
+	}
+	if strings.HasPrefix(image, "github.com/") {
+		raw := regexp.QuoteMeta("raw.githubusercontent.com/" + strings.TrimPrefix(image, "github.com/"))
+		prefixes = append(prefixes, raw+`/`)
+	}
+	re, err := regexp.Compile(fmt.Sprintf(`(%s)[\w.+-]*\d[\w.+-]*`, strings.Join(prefixes, "|")))
+	if err != nil {
+		return nil, err
+	}
+	return re.ReplaceAll(content, []byte("${1}"+strings.ReplaceAll(tag, "$", "$$"))), nil
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"testing"
+)
+
+func TestGitRefReplacer(t *testing.T) {
+	content := `resources:
+- https://github.com/acme/crds/releases/download/v0.1.0/crds.yaml
+- https://raw.githubusercontent.com/acme/crds/v0.1.0/deploy/rbac.yaml
+- github.com/acme/crds//config/default?ref=v0.1.0
+- https://github.com/acme/crds-extra/releases/download/v0.1.0/crds.yaml
+- https://github.com/acme/crds/archive/refs/heads/main.tar.gz
+`
+	want := `resources:
+- https://github.com/acme/crds/releases/download/v0.2.0/crds.yaml
+- https://raw.githubusercontent.com/acme/crds/v0.2.0/deploy/rbac.yaml
+- github.com/acme/crds//config/default?ref=v0.2.0
+- https://github.com/acme/crds-extra/releases/download/v0.1.0/crds.yaml
+- https://github.com/acme/crds/archive/refs/heads/main.tar.gz
+`
+	got, err := GitRefReplacer{}.Replace("kustomization.yaml", []byte(content), "github.com/acme/crds", "v0.2.0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gopkg.in/yaml.v3"
+)
+
+// kustomizationFiles lists the file names kustomize reads a kustomization
+// from.
+var kustomizationFiles = map[string]bool{
+	"kustomization.yaml": true,
+	"kustomization.yml":  true,
+	"Kustomization":      true,
+}
+
+// KustomizeReplacer rewrites the `images` transformer entries of
+// kustomizations whose `name` or `newName` is image, setting `newTag`
+// and `digest`. When the kustomization at the top of Path has no such
+// entry and Name is set, one is added to it.
+type KustomizeReplacer struct {
+	// Name is the image as spelled in the resources of the kustomization,
+	// which kustomize matches the `name` of new entries against literally.
+	Name string
+}
+
+func (k KustomizeReplacer) Replace(path string, content []byte, image, tag, digest string) ([]byte, error) {
+	if !kustomizationFiles[filepath.Base(path)] {
+		return content, nil
+	}
+	target, err := repositoryName(image)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := decodeYAML(content)
+	if err != nil || len(docs) == 0 || len(docs[0].Content) == 0 {
+		return content, nil
+	}
+	root := docs[0].Content[0]
+	if root.Kind != yaml.MappingNode {
+		return content, nil
+	}
+
+	lines := lineOffsets(content)
+	images := mappingValue(root, "images")
+	if images != nil && images.Kind == yaml.SequenceNode {
+		var (
+			spans   []span
+			matched bool
+		)
+		for _, entry := range images.Content {
+			if !matchKustomizeImage(entry, target) {
+				continue
+			}
+			matched = true
+			spans = append(spans, kustomizeImageSpans(content, lines, entry, tag, digest)...)
+		}
+		if matched {
+			return splice(content, spans), nil
+		}
+	}
+
+	// Only the overlay at the top of Path gets a new entry, its bases are
+	// left alone.
+	if k.Name == "" || filepath.Dir(path) != "." {
+		return content, nil
+	}
+	if images != nil && images.Kind == yaml.SequenceNode && len(images.Content) > 0 {
+		first := images.Content[0]
+		if images.Style&yaml.FlowStyle != 0 || first.Column < 3 {
+			return content, nil
+		}
+		indent := strings.Repeat(" ", first.Column-1)
+		text := "- " + strings.Join(kustomizeImageFields(k.Name, tag, digest), "\n"+indent)
+		at := lineEnd(content, lines, lastLine(images))
+		return splice(content, []span{insertLine(content, at, strings.Repeat(" ", first.Column-3)+text)}), nil
+	}
+	if images != nil {
+		// Leave an empty `images:` or `images: []` alone rather than
+		// guessing its layout.
+		return content, nil
+	}
+	text := "images:\n- " + strings.Join(kustomizeImageFields(k.Name, tag, digest), "\n  ")
+	return splice(content, []span{insertLine(content, len(content), text)}), nil
+}
+
+// matchKustomizeImage reports whether the images entry refers to the
+// repository target, by its `name` or its `newName`.
+func matchKustomizeImage(entry *yaml.Node, target string) bool {
+	for _, key := range []string{"name", "newName"} {
+		v := mappingValue(entry, key)
+		if v == nil {
+			continue
+		}
+		repo, err := name.NewRepository(v.Value)
+		if err == nil && repo.Name() == target {
+			return true
+		}
+	}
+	return false
+}
+
+// kustomizeImageSpans returns the spans setting newTag and digest in the
+// images entry, adding the keys missing and dropping the ones unset.
+func kustomizeImageSpans(content []byte, lines []int, entry *yaml.Node, tag, digest string) []span {
+	if entry.Kind != yaml.MappingNode || entry.Style&yaml.FlowStyle != 0 {
+		return nil
+	}
+	indent := strings.Repeat(" ", entry.Column-1)
+	at := lineEnd(content, lines, lastLine(entry))
+
+	var spans []span
+	for _, field := range []struct{ key, value string }{{"newTag", tag}, {"digest", digest}} {
+		key, value := mappingKey(entry, field.key)
+		switch {
+		case key == nil && field.value != "":
+			spans = append(spans, insertLine(content, at, fmt.Sprintf("%s%s: %s", indent, field.key, yamlString(field.value))))
+		case key != nil && field.value == "" && key.Line != entry.Line && value.Line == key.Line:
+			// Drop the whole line, unless the key shares it with `- `.
+			spans = append(spans, span{start: lines[key.Line-1], end: lineEnd(content, lines, key.Line)})
+		case key != nil && field.value != "":
+			if s, ok := (scalarEdit{node: value, value: field.value}).span(content, lines); ok {
+				spans = append(spans, s)
+			}
+		}
+	}
+	return spans
+}
+
+func kustomizeImageFields(image, tag, digest string) []string {
+	fields := []string{"name: " + yamlString(image)}
+	if tag != "" {
+		fields = append(fields, "newTag: "+yamlString(tag))
+	}
+	if digest != "" {
+		fields = append(fields, "digest: "+yamlString(digest))
+	}
+	return fields
+}
+
+// insertLine returns the span inserting text as a line of its own at the
+// offset at, which is the start of a line or the end of content.
+func insertLine(content []byte, at int, text string) span {
+	if at == len(content) && at > 0 && content[at-1] != '\n' {
+		text = "\n" + text
+	}
+	return span{start: at, end: at, text: text + "\n"}
+}
+
+// lastLine returns the last line n or its children start on.
+func lastLine(n *yaml.Node) int {
+	line := n.Line
+	for _, c := range n.Content {
+		if l := lastLine(c); l > line {
+			line = l
+		}
+	}
+	return line
+}
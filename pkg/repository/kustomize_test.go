@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"testing"
+)
+
+func TestKustomizeReplacer(t *testing.T) {
+	const digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	tests := []struct {
+		name    string
+		path    string
+		image   string
+		tag     string
+		digest  string
+		content string
+		want    string
+	}{
+		{
+			name: "existing entry",
+			path: "kustomization.yaml",
+			tag:  "v2",
+			content: `resources:
+- ../../base
+images:
+- name: docker.io/acme/app # the api
+  newTag: v1
+- name: acme/worker
+  newTag: v1
+`,
+			want: `resources:
+- ../../base
+images:
+- name: docker.io/acme/app # the api
+  newTag: v2
+- name: acme/worker
+  newTag: v1
+`,
+		},
+		{
+			name:   "entry matched by newName",
+			path:   "kustomization.yaml",
+			digest: digest,
+			content: `images:
+  - name: app
+    newName: acme/app
+    newTag: v1
+`,
+			want: `images:
+  - name: app
+    newName: acme/app
+    digest: ` + digest + `
+`,
+		},
+		{
+			name:  "new entry",
+			path:  "kustomization.yml",
+			image: "acme/app",
+			tag:   "v2",
+			content: `images:
+- name: acme/worker
+  newTag: v1
+configMapGenerator: []
+`,
+			want: `images:
+- name: acme/worker
+  newTag: v1
+- name: acme/app
+  newTag: v2
+configMapGenerator: []
+`,
+		},
+		{
+			name:    "new images",
+			path:    "Kustomization",
+			image:   "acme/app",
+			tag:     "v2",
+			content: "resources:\n- deployment.yaml",
+			want:    "resources:\n- deployment.yaml\nimages:\n- name: acme/app\n  newTag: v2\n",
+		},
+		{
+			name:    "new images spelled as in the resources",
+			path:    "kustomization.yaml",
+			image:   "docker.io/acme/app",
+			tag:     "v2",
+			content: "resources:\n- deployment.yaml\n",
+			want:    "resources:\n- deployment.yaml\nimages:\n- name: docker.io/acme/app\n  newTag: v2\n",
+		},
+		{
+			name: "numeric tags",
+			path: "kustomization.yaml",
+			tag:  "20210301120000",
+			content: `images:
+- name: acme/app
+  newTag: v1
+- name: docker.io/acme/app
+  digest: sha256:0000000000000000000000000000000000000000000000000000000000000000
+`,
+			want: `images:
+- name: acme/app
+  newTag: "20210301120000"
+- name: docker.io/acme/app
+  newTag: "20210301120000"
+`,
+		},
+		{
+			name:    "new entry with a float tag",
+			path:    "kustomization.yaml",
+			image:   "acme/app",
+			tag:     "1.10",
+			content: "resources:\n- deployment.yaml\n",
+			want:    "resources:\n- deployment.yaml\nimages:\n- name: acme/app\n  newTag: \"1.10\"\n",
+		},
+		{
+			name:    "no new entry without image",
+			path:    "kustomization.yaml",
+			tag:     "v2",
+			content: "resources:\n- deployment.yaml\n",
+			want:    "resources:\n- deployment.yaml\n",
+		},
+		{
+			name:    "base",
+			path:    "base/kustomization.yaml",
+			image:   "acme/app",
+			tag:     "v2",
+			content: "resources:\n- deployment.yaml\n",
+			want:    "resources:\n- deployment.yaml\n",
+		},
+		{
+			name:    "not a kustomization",
+			path:    "deployment.yaml",
+			tag:     "v2",
+			content: "images:\n- name: acme/app\n  newTag: v1\n",
+			want:    "images:\n- name: acme/app\n  newTag: v1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := KustomizeReplacer{Name: tt.image}.Replace(tt.path, []byte(tt.content), "index.docker.io/acme/app", tt.tag, tt.digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
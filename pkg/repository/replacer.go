@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// StrategyImage rewrites the image fields of workload manifests.
+	StrategyImage = "image"
+	// StrategyKustomize rewrites the images transformer of kustomizations.
+	StrategyKustomize = "kustomize"
+	// StrategyHelmValues rewrites the image tags of Helm values files.
+	StrategyHelmValues = "helmValues"
+	// StrategySetters rewrites the fields marked with Flux compatible
+	// image policy comments naming the Updater.
+	StrategySetters = "setters"
+)
+
+var (
+	ErrUnknownStrategy = errors.New("unknown update strategy")
+)
+
+// Replacer rewrites the references to image found in content, the content
+// of the file at path relative to Path, so that they point to tag and
+// digest.
+type Replacer interface {
+	Replace(path string, content []byte, image, tag, digest string) ([]byte, error)
+}
+
+// ReplacerOptions configures the replacers returned by NewReplacer.
+type ReplacerOptions struct {
+	// ValuesFiles are the values files rewritten by StrategyHelmValues.
+	ValuesFiles []string
+	// ValuesKeys are the key paths set by StrategyHelmValues.
+	ValuesKeys []string
+	// Policy is the `namespace:name` the markers rewritten by
+	// StrategySetters refer to.
+	Policy string
+	// Image is the image as written in the manifests, under which
+	// StrategyKustomize adds new `images` entries.
+	Image string
+}
+
+// NewReplacer returns the Replacer implementing the update strategy.
+func NewReplacer(strategy string, opts ReplacerOptions) (Replacer, error) {
+	switch strategy {
+	case "", StrategyImage:
+		return ImageReplacer{}, nil
+	case StrategyKustomize:
+		return KustomizeReplacer{Name: opts.Image}, nil
+	case StrategyHelmValues:
+		return HelmValuesReplacer{Files: opts.ValuesFiles, Keys: opts.ValuesKeys}, nil
+	case StrategySetters:
+		return SetterReplacer{Policy: opts.Policy}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownStrategy, strategy)
+}
+
+// podSpecPaths lists the path from the top of a manifest to its pod spec
+// by kind.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"PodTemplate":           {"template", "spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+var containerFields = []string{"containers", "initContainers", "ephemeralContainers"}
+
+// ImageReplacer rewrites the `image` field of the containers, init
+// containers and ephemeral containers of workload manifests. Other files,
+// and other mentions of the image such as comments, are left untouched.
+// References are compared by the repository they resolve to, so `nginx`,
+// `docker.io/library/nginx` and `index.docker.io/library/nginx` all match
+// each other, and the name is kept as spelled in the file.
+type ImageReplacer struct{}
+
+func (ImageReplacer) Replace(path string, content []byte, image, tag, digest string) ([]byte, error) {
+	if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+		return content, nil
+	}
+	target, err := repositoryName(image)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := decodeYAML(content)
+	if err != nil {
+		// Not every file below Path has to be a manifest, e.g. templates.
+		return content, nil
+	}
+
+	var edits []scalarEdit
+	for _, doc := range docs {
+		for _, node := range imageNodes(doc) {
+			ref, err := name.ParseReference(node.Value)
+			if err != nil || ref.Context().Name() != target {
+				continue
+			}
+			edits = append(edits, scalarEdit{
+				node:  node,
+				value: formatReference(trimReference(node.Value), tag, digest),
+			})
+		}
+	}
+	return applyEdits(content, edits), nil
+}
+
+// imageNodes returns the scalar `image` nodes of the containers in the
+// manifest doc, descending into the items of lists.
+func imageNodes(doc *yaml.Node) []*yaml.Node {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	kind := mappingValue(doc, "kind")
+	if kind == nil {
+		return nil
+	}
+
+	var nodes []*yaml.Node
+	if strings.HasSuffix(kind.Value, "List") {
+		if items := mappingValue(doc, "items"); items != nil && items.Kind == yaml.SequenceNode {
+			for _, item := range items.Content {
+				nodes = append(nodes, imageNodes(item)...)
+			}
+		}
+		return nodes
+	}
+
+	path, ok := podSpecPaths[kind.Value]
+	if !ok {
+		return nil
+	}
+	spec := doc
+	for _, key := range path {
+		if spec = mappingValue(spec, key); spec == nil {
+			return nil
+		}
+	}
+	for _, field := range containerFields {
+		containers := mappingValue(spec, field)
+		if containers == nil || containers.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, c := range containers.Content {
+			if image := mappingValue(c, "image"); image != nil && image.Kind == yaml.ScalarNode {
+				nodes = append(nodes, image)
+			}
+		}
+	}
+	return nodes
+}
+
+// repositoryName returns the fully qualified repository of the reference
+// image, which may carry a tag or a digest.
+func repositoryName(image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	return ref.Context().Name(), nil
+}
+
+// trimReference strips the tag and the digest from the reference ref.
+func trimReference(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+func formatReference(image, tag, digest string) string {
+	ref := image
+	if tag != "" {
+		ref += ":" + tag
+	}
+	if digest != "" {
+		ref += "@" + digest
+	}
+	return ref
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"testing"
+)
+
+func TestImageReplacer(t *testing.T) {
+	const (
+		oldDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		newDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	)
+	tests := []struct {
+		name    string
+		path    string
+		image   string
+		tag     string
+		digest  string
+		content string
+		want    string
+	}{
+		{
+			name:  "equivalent spellings",
+			path:  "deployment.yaml",
+			image: "index.docker.io/library/nginx",
+			tag:   "1.19",
+			content: `# nginx:1.18 is pinned below
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: docker.io/library/nginx:1.18 # keep in sync
+      containers:
+      - name: nginx
+        image: "nginx:1.18"
+        args: ["nginx:1.18"]
+      - name: sidecar
+        image: acme/nginx:1.18
+      - name: mirror
+        image: mirror.example.com/library/nginx:1.18
+`,
+			want: `# nginx:1.18 is pinned below
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: docker.io/library/nginx:1.19 # keep in sync
+      containers:
+      - name: nginx
+        image: "nginx:1.19"
+        args: ["nginx:1.18"]
+      - name: sidecar
+        image: acme/nginx:1.18
+      - name: mirror
+        image: mirror.example.com/library/nginx:1.18
+`,
+		},
+		{
+			name:  "multiple documents",
+			path:  "workloads.yml",
+			image: "registry.example.com:5000/acme/app",
+			tag:   "v2",
+			content: `kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - image: registry.example.com:5000/acme/app:v1
+---
+kind: ConfigMap
+data:
+  image: registry.example.com:5000/acme/app:v1
+---
+kind: Pod
+spec:
+  ephemeralContainers:
+  - image: 'registry.example.com:5000/acme/app'
+`,
+			want: `kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - image: registry.example.com:5000/acme/app:v2
+---
+kind: ConfigMap
+data:
+  image: registry.example.com:5000/acme/app:v1
+---
+kind: Pod
+spec:
+  ephemeralContainers:
+  - image: 'registry.example.com:5000/acme/app:v2'
+`,
+		},
+		{
+			name:    "digest",
+			path:    "pod.yaml",
+			image:   "acme/app",
+			digest:  newDigest,
+			content: "kind: Pod\nspec:\n  containers:\n  - image: acme/app@" + oldDigest + "\n  - image: docker.io/acme/app:v1@" + oldDigest + "\n",
+			want:    "kind: Pod\nspec:\n  containers:\n  - image: acme/app@" + newDigest + "\n  - image: docker.io/acme/app@" + newDigest + "\n",
+		},
+		{
+			name:    "tag and digest",
+			path:    "pod.yaml",
+			image:   "acme/app",
+			tag:     "v2",
+			digest:  newDigest,
+			content: "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v1@" + oldDigest + "\n",
+			want:    "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v2@" + newDigest + "\n",
+		},
+		{
+			name:    "not yaml",
+			path:    "README.md",
+			image:   "acme/app",
+			tag:     "v2",
+			content: "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v1\n",
+			want:    "kind: Pod\nspec:\n  containers:\n  - image: acme/app:v1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ImageReplacer{}.Replace(tt.path, []byte(tt.content), tt.image, tt.tag, tt.digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
@@ -5,13 +5,24 @@ import (
 	"errors"
 )
 
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+	// ProviderBitbucketServer also covers Bitbucket Data Center.
+	ProviderBitbucketServer = "bitbucketServer"
+	ProviderAzureDevOps     = "azureDevOps"
+)
+
 var (
 	ErrTagAlreadyUpToDate       = errors.New("tag already up to date")
 	ErrTagNotReplaced           = errors.New("tag not replaced")
 	ErrPullRequestAlreadyExists = errors.New("pull request already exists")
+	ErrInvalidRepositoryURL     = errors.New("invalid repository url")
+	ErrUnknownProvider          = errors.New("unknown repository provider")
 )
 
 type Repository interface {
-	PushReplaceTagCommit(ctx context.Context, image, tag string) error
+	PushReplaceTagCommit(ctx context.Context, image, tag, digest string) error
 	CreatePullRequest(ctx context.Context) error
 }
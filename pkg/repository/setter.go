@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const imagePolicyMarker = "$imagepolicy"
+
+// SetterReplacer only rewrites the YAML fields carrying a Flux compatible
+// marker naming Policy, the `namespace:name` of the Updater, in their line
+// comment:
+//
+//	image: acme/app:1.2.3 # {"$imagepolicy": "team-a:app"}
+//	tag: 1.2.3 # {"$imagepolicy": "team-a:app:tag"}
+//
+// A marker without suffix sets the whole reference, `:tag` the tag, `:name`
+// the image name and `:digest` the digest.
+type SetterReplacer struct {
+	Policy string
+}
+
+func (s SetterReplacer) Replace(path string, content []byte, image, tag, digest string) ([]byte, error) {
+	if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+		return content, nil
+	}
+	if !strings.Contains(string(content), imagePolicyMarker) {
+		return content, nil
+	}
+	docs, err := decodeYAML(content)
+	if err != nil {
+		return content, nil
+	}
+
+	values := map[string]string{
+		"":       formatReference(image, tag, digest),
+		"tag":    tag,
+		"name":   image,
+		"digest": digest,
+	}
+	var edits []scalarEdit
+	for _, doc := range docs {
+		walkScalars(doc, func(n *yaml.Node) {
+			field, ok := s.markedField(n.LineComment)
+			if !ok {
+				return
+			}
+			if value := values[field]; value != "" {
+				edits = append(edits, scalarEdit{node: n, value: value})
+			}
+		})
+	}
+	return applyEdits(content, edits), nil
+}
+
+// markedField returns the field, empty for the whole reference, the
+// marker in comment sets if it names Policy.
+func (s SetterReplacer) markedField(comment string) (string, bool) {
+	comment = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "#"))
+	if !strings.HasPrefix(comment, "{") {
+		return "", false
+	}
+	var marker map[string]string
+	if err := json.Unmarshal([]byte(comment), &marker); err != nil {
+		return "", false
+	}
+	policy, ok := marker[imagePolicyMarker]
+	if !ok {
+		return "", false
+	}
+	if policy == s.Policy {
+		return "", true
+	}
+	if i := strings.LastIndex(policy, ":"); i >= 0 && policy[:i] == s.Policy {
+		field := policy[i+1:]
+		switch field {
+		case "tag", "name", "digest":
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// walkScalars calls fn for every scalar node below n.
+func walkScalars(n *yaml.Node, fn func(n *yaml.Node)) {
+	if n.Kind == yaml.ScalarNode {
+		fn(n)
+	}
+	for _, c := range n.Content {
+		walkScalars(c, fn)
+	}
+}
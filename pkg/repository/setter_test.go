@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"testing"
+)
+
+func TestSetterReplacer(t *testing.T) {
+	content := `spec:
+  containers:
+  - image: acme/app:1.0.0 # {"$imagepolicy": "team-a:app"}
+  - image: acme/app:1.0.0 # {"$imagepolicy": "team-b:app"}
+  - image: acme/app:1.0.0
+---
+image:
+  repository: "acme/app" # {"$imagepolicy": "team-a:app:name"}
+  tag: 1.0.0 # {"$imagepolicy": "team-a:app:tag"}
+  digest: "" # {"$imagepolicy": "team-a:app:digest"}
+`
+	want := `spec:
+  containers:
+  - image: mirror.example.com/acme/app:1.1.0 # {"$imagepolicy": "team-a:app"}
+  - image: acme/app:1.0.0 # {"$imagepolicy": "team-b:app"}
+  - image: acme/app:1.0.0
+---
+image:
+  repository: "mirror.example.com/acme/app" # {"$imagepolicy": "team-a:app:name"}
+  tag: 1.1.0 # {"$imagepolicy": "team-a:app:tag"}
+  digest: "" # {"$imagepolicy": "team-a:app:digest"}
+`
+	got, err := SetterReplacer{Policy: "team-a:app"}.Replace("app.yaml", []byte(content), "mirror.example.com/acme/app", "1.1.0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestSetterReplacerNumericTags(t *testing.T) {
+	content := "tag: 20210301110000 # {\"$imagepolicy\": \"team-a:app:tag\"}\n"
+	want := "tag: \"20210301120000\" # {\"$imagepolicy\": \"team-a:app:tag\"}\n"
+	got, err := SetterReplacer{Policy: "team-a:app"}.Replace("app.yaml", []byte(content), "acme/app", "20210301120000", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
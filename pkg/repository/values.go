@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultValuesFiles are the values files rewritten when none are given.
+var DefaultValuesFiles = []string{"values*.yaml", "values*.yml"}
+
+// HelmValuesReplacer rewrites Helm values files. It updates the `tag` and
+// `digest` next to each `repository`, optionally prefixed by a sibling
+// `registry`, naming image, and the scalars at Keys.
+type HelmValuesReplacer struct {
+	// Files are glob patterns of the values files to rewrite. Patterns
+	// without a slash match the file name in any directory, the others
+	// the path relative to Path.
+	Files []string
+	// Keys are dotted key paths such as `backend.image.tag` set to the
+	// tag, or to the digest for keys named `digest`.
+	Keys []string
+}
+
+func (h HelmValuesReplacer) Replace(path string, content []byte, image, tag, digest string) ([]byte, error) {
+	if !h.matchFile(path) {
+		return content, nil
+	}
+	target, err := repositoryName(image)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := decodeYAML(content)
+	if err != nil {
+		return content, nil
+	}
+
+	var edits []scalarEdit
+	set := func(node *yaml.Node, value string) {
+		if node != nil && node.Kind == yaml.ScalarNode && value != "" {
+			edits = append(edits, scalarEdit{node: node, value: value})
+		}
+	}
+	for _, doc := range docs {
+		walkMappings(doc, func(m *yaml.Node) {
+			repo := mappingValue(m, "repository")
+			if repo == nil || repo.Kind != yaml.ScalarNode {
+				return
+			}
+			ref := repo.Value
+			if registry := mappingValue(m, "registry"); registry != nil && registry.Value != "" {
+				ref = strings.TrimSuffix(registry.Value, "/") + "/" + ref
+			}
+			if name, err := repositoryName(ref); err != nil || name != target {
+				return
+			}
+			set(mappingValue(m, "tag"), tag)
+			set(mappingValue(m, "digest"), digest)
+		})
+		for _, key := range h.Keys {
+			// Keys other than digests are left alone when pinning
+			// the digest only.
+			value := tag
+			if strings.HasSuffix(key, "digest") {
+				value = digest
+			}
+			set(lookupKey(doc, key), value)
+		}
+	}
+	return applyEdits(content, dedupEdits(edits)), nil
+}
+
+func (h HelmValuesReplacer) matchFile(path string) bool {
+	patterns := h.Files
+	if len(patterns) == 0 {
+		patterns = DefaultValuesFiles
+	}
+	for _, p := range patterns {
+		target := path
+		if !strings.Contains(p, "/") {
+			target = filepath.Base(path)
+		}
+		if ok, _ := filepath.Match(strings.TrimPrefix(p, "/"), target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupKey returns the node at the dotted key path below doc.
+func lookupKey(doc *yaml.Node, key string) *yaml.Node {
+	n := doc
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	for _, k := range strings.Split(key, ".") {
+		if n = mappingValue(n, k); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// dedupEdits drops the edits of nodes already edited.
+func dedupEdits(edits []scalarEdit) []scalarEdit {
+	seen := map[*yaml.Node]bool{}
+	deduped := edits[:0]
+	for _, e := range edits {
+		if !seen[e.node] {
+			seen[e.node] = true
+			deduped = append(deduped, e)
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"testing"
+)
+
+func TestHelmValuesReplacer(t *testing.T) {
+	const digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	content := `frontend:
+  image:
+    repository: acme/web
+    tag: v1
+backend:
+  image:
+    registry: docker.io
+    repository: acme/app
+    tag: "v1" # the api
+    digest: ""
+worker:
+  image: acme/app
+  version: v1
+`
+	tests := []struct {
+		name     string
+		replacer HelmValuesReplacer
+		path     string
+		tag      string
+		want     string
+	}{
+		{
+			name:     "detected pairs",
+			replacer: HelmValuesReplacer{},
+			path:     "charts/app/values.yaml",
+			tag:      "v2",
+			want: `frontend:
+  image:
+    repository: acme/web
+    tag: v1
+backend:
+  image:
+    registry: docker.io
+    repository: acme/app
+    tag: "v2" # the api
+    digest: "` + digest + `"
+worker:
+  image: acme/app
+  version: v1
+`,
+		},
+		{
+			name:     "key paths",
+			replacer: HelmValuesReplacer{Files: []string{"values-prd.yaml"}, Keys: []string{"worker.version", "backend.image.tag"}},
+			path:     "values-prd.yaml",
+			tag:      "v2",
+			want: `frontend:
+  image:
+    repository: acme/web
+    tag: v1
+backend:
+  image:
+    registry: docker.io
+    repository: acme/app
+    tag: "v2" # the api
+    digest: "` + digest + `"
+worker:
+  image: acme/app
+  version: v2
+`,
+		},
+		{
+			name:     "digest only",
+			replacer: HelmValuesReplacer{Files: []string{"values-prd.yaml"}, Keys: []string{"worker.version", "backend.image.tag", "backend.image.digest"}},
+			path:     "values-prd.yaml",
+			want: `frontend:
+  image:
+    repository: acme/web
+    tag: v1
+backend:
+  image:
+    registry: docker.io
+    repository: acme/app
+    tag: "v1" # the api
+    digest: "` + digest + `"
+worker:
+  image: acme/app
+  version: v1
+`,
+		},
+		{
+			name:     "other files",
+			replacer: HelmValuesReplacer{Files: []string{"values-prd.yaml"}},
+			path:     "values.yaml",
+			want:     content,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.replacer.Replace(tt.path, []byte(content), "index.docker.io/acme/app", tt.tag, digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHelmValuesReplacerNumericTags(t *testing.T) {
+	content := "image:\n  repository: acme/app\n  tag: 1.9\nbuild: 20210301110000\n"
+	want := "image:\n  repository: acme/app\n  tag: \"1.10\"\nbuild: \"1.10\"\n"
+	replacer := HelmValuesReplacer{Files: []string{"values.yaml"}, Keys: []string{"image.tag", "build"}}
+	got, err := replacer.Replace("values.yaml", []byte(content), "acme/app", "1.10", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scalarEdit replaces the value of a scalar node.
+type scalarEdit struct {
+	node  *yaml.Node
+	value string
+}
+
+// decodeYAML parses every document of content.
+func decodeYAML(content []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, err
+		}
+		docs = append(docs, &doc)
+	}
+}
+
+// span replaces content[start:end] with text.
+type span struct {
+	start, end int
+	text       string
+}
+
+// applyEdits splices the edited scalars into content in place, so that
+// comments, ordering, indentation and quoting of the rest of the file are
+// left untouched.
+func applyEdits(content []byte, edits []scalarEdit) []byte {
+	lines := lineOffsets(content)
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		if s, ok := e.span(content, lines); ok {
+			spans = append(spans, s)
+		}
+	}
+	return splice(content, spans)
+}
+
+// span returns the span replacing the scalar, keeping its quoting style.
+// Plain scalars are quoted when the value would not read back as a string.
+func (e scalarEdit) span(content []byte, lines []int) (span, bool) {
+	if e.node.Value == e.value || e.node.Line < 1 || e.node.Line > len(lines) {
+		return span{}, false
+	}
+	start := columnOffset(content, lines[e.node.Line-1], e.node.Column)
+	end := scalarEnd(content, start, e.node)
+	if end < 0 {
+		return span{}, false
+	}
+	value := e.value
+	switch e.node.Style {
+	case yaml.DoubleQuotedStyle:
+		value = `"` + value + `"`
+	case yaml.SingleQuotedStyle:
+		value = `'` + strings.ReplaceAll(value, `'`, `''`) + `'`
+	default:
+		value = yamlString(value)
+	}
+	return span{start: start, end: end, text: value}, true
+}
+
+// yamlString returns value as a plain scalar, or double quoted when YAML
+// would not read it back as the same string, e.g. the float `1.10` or the
+// int `20210301120000`.
+func yamlString(value string) string {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err == nil && len(doc.Content) == 1 {
+		n := doc.Content[0]
+		if n.Kind == yaml.ScalarNode && n.Style == 0 && n.Tag == "!!str" && n.Value == value {
+			return value
+		}
+	}
+	return strconv.Quote(value)
+}
+
+// splice applies the non overlapping spans to content.
+func splice(content []byte, spans []span) []byte {
+	if len(spans) == 0 {
+		return content
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var buf bytes.Buffer
+	var last int
+	for _, s := range spans {
+		if s.start < last {
+			continue
+		}
+		buf.Write(content[last:s.start])
+		buf.WriteString(s.text)
+		last = s.end
+	}
+	buf.Write(content[last:])
+	return buf.Bytes()
+}
+
+func lineOffsets(content []byte) []int {
+	offsets := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// lineEnd returns the offset right after the end of the 1-based line,
+// including its line break.
+func lineEnd(content []byte, lines []int, line int) int {
+	if line < len(lines) {
+		return lines[line]
+	}
+	return len(content)
+}
+
+// columnOffset returns the byte offset of the 1-based character column of
+// the line starting at offset.
+func columnOffset(content []byte, offset, column int) int {
+	for i := 1; i < column && offset < len(content); i++ {
+		_, size := utf8.DecodeRune(content[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// scalarEnd returns the offset right after the single line scalar node
+// starting at start, or -1 if it cannot be edited in place.
+func scalarEnd(content []byte, start int, node *yaml.Node) int {
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := start + 1; i < len(content) && content[i] != '\n'; i++ {
+			switch content[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := start + 1; i < len(content) && content[i] != '\n'; i++ {
+			if content[i] != '\'' {
+				continue
+			}
+			if i+1 < len(content) && content[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	case 0, yaml.FlowStyle:
+		end := start + len(node.Value)
+		if end <= len(content) && string(content[start:end]) == node.Value {
+			return end
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value of key in the mapping node m.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	_, value := mappingKey(m, key)
+	return value
+}
+
+// mappingKey returns the key and value nodes of key in the mapping node m.
+func mappingKey(m *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// walkMappings calls fn for every mapping node below n.
+func walkMappings(n *yaml.Node, fn func(m *yaml.Node)) {
+	if n.Kind == yaml.MappingNode {
+		fn(n)
+	}
+	for _, c := range n.Content {
+		walkMappings(c, fn)
+	}
+}
@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+var (
+	ErrNoRepository = errors.New("No repository in payload")
+)
+
+// https://docs.docker.com/docker-hub/webhooks/
+type dockerHubPayload struct {
+	Repository struct {
+		RepoName string `json:"repo_name"`
+	} `json:"repository"`
+}
+
+func parseDockerHub(payload []byte) ([]string, error) {
+	var p dockerHubPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	if p.Repository.RepoName == "" {
+		return nil, ErrNoRepository
+	}
+	return []string{p.Repository.RepoName}, nil
+}
+
+// https://goharbor.io/docs/main/working-with-projects/project-configuration/configure-webhooks/
+type harborPayload struct {
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			ResourceURL string `json:"resource_url"`
+		} `json:"resources"`
+	} `json:"event_data"`
+}
+
+func parseHarbor(payload []byte) ([]string, error) {
+	var p harborPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	if p.Type != "PUSH_ARTIFACT" && p.Type != "pushImage" {
+		return nil, nil
+	}
+	var repositories []string
+	for _, r := range p.EventData.Resources {
+		ref, err := name.ParseReference(r.ResourceURL)
+		if err != nil {
+			return nil, err
+		}
+		repositories = append(repositories, ref.Context().Name())
+	}
+	if len(repositories) == 0 {
+		return nil, ErrNoRepository
+	}
+	return repositories, nil
+}
+
+// https://distribution.github.io/distribution/about/notifications/
+type distributionPayload struct {
+	Events []struct {
+		Action string `json:"action"`
+		Target struct {
+			Repository string `json:"repository"`
+			Tag        string `json:"tag"`
+		} `json:"target"`
+		Request struct {
+			Host string `json:"host"`
+		} `json:"request"`
+	} `json:"events"`
+}
+
+func parseDistribution(payload []byte) ([]string, error) {
+	var p distributionPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	var repositories []string
+	for _, e := range p.Events {
+		// Blob and untagged manifest pushes are notified as well.
+		if e.Action != "push" || e.Target.Tag == "" {
+			continue
+		}
+		repository := e.Target.Repository
+		if e.Request.Host != "" {
+			repository = e.Request.Host + "/" + repository
+		}
+		repositories = append(repositories, repository)
+	}
+	return repositories, nil
+}
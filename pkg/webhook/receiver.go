@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+var (
+	ErrUnauthorized = errors.New("Unauthorized")
+	ErrNoSecret     = errors.New("No webhook secret")
+)
+
+const maxPayloadSize = 1 << 20
+
+// Notifier is told about repositories an image was pushed to.
+type Notifier interface {
+	Notify(repository string)
+}
+
+// Receiver accepts push notifications of Docker Hub, Harbor and CNCF
+// Distribution registries and passes the pushed repositories on to a
+// Notifier.
+//
+// Requests have to carry Secret either as an HMAC-SHA256 signature of the
+// body in the X-Hub-Signature-256 header, in the Authorization header, or
+// as the `token` query parameter.
+type Receiver struct {
+	Secret   string
+	Notifier Notifier
+	Logger   logr.Logger
+}
+
+// parser extracts the pushed repositories from a notification payload.
+type parser func(payload []byte) ([]string, error)
+
+func (r *Receiver) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/webhook/dockerhub", r.handle(parseDockerHub))
+	mux.Handle("/webhook/harbor", r.handle(parseHarbor))
+	mux.Handle("/webhook/distribution", r.handle(parseDistribution))
+	return mux
+}
+
+// ListenAndServe serves the receiver on addr until stop is closed or
+// receives a value. It refuses to serve without a Secret, since anyone
+// able to reach addr could trigger the Updaters otherwise.
+func (r *Receiver) ListenAndServe(addr string, stop <-chan struct{}) error {
+	if r.Secret == "" {
+		return ErrNoSecret
+	}
+	server := &http.Server{Addr: addr, Handler: r.Handler()}
+	go func() {
+		<-stop
+		server.Shutdown(context.Background())
+	}()
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (r *Receiver) handle(parse parser) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		payload, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxPayloadSize))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := r.verify(req, payload); err != nil {
+			r.Logger.Error(err, "Webhook", "path", req.URL.Path)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		repositories, err := parse(payload)
+		if err != nil {
+			r.Logger.Error(err, "Webhook", "path", req.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, repository := range repositories {
+			r.Logger.Info("Image was pushed", "repository", repository)
+			r.Notifier.Notify(repository)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func (r *Receiver) verify(req *http.Request, payload []byte) error {
+	if r.Secret == "" {
+		return ErrNoSecret
+	}
+	if signature := req.Header.Get("X-Hub-Signature-256"); signature != "" {
+		mac := hmac.New(sha256.New, []byte(r.Secret))
+		mac.Write(payload)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+		return ErrUnauthorized
+	}
+
+	token := req.URL.Query().Get("token")
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(r.Secret)) == 1 {
+		return nil
+	}
+	return ErrUnauthorized
+}
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	logrtesting "github.com/go-logr/logr/testing"
+)
+
+type recorder []string
+
+func (r *recorder) Notify(repository string) {
+	*r = append(*r, repository)
+}
+
+func TestReceiver(t *testing.T) {
+	const secret = "s3cr3t"
+
+	sign := func(payload string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(payload))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		payload string
+		header  map[string]string
+		status  int
+		want    []string
+	}{
+		{
+			name:    "docker hub with token",
+			path:    "/webhook/dockerhub?token=" + secret,
+			payload: `{"push_data":{"tag":"1.0.0"},"repository":{"repo_name":"acme/app"}}`,
+			status:  http.StatusAccepted,
+			want:    []string{"acme/app"},
+		},
+		{
+			name:    "harbor with auth header",
+			path:    "/webhook/harbor",
+			payload: `{"type":"PUSH_ARTIFACT","event_data":{"resources":[{"resource_url":"harbor.example.com/library/app:1.0.0"}]}}`,
+			header:  map[string]string{"Authorization": secret},
+			status:  http.StatusAccepted,
+			want:    []string{"harbor.example.com/library/app"},
+		},
+		{
+			name:    "distribution with signature",
+			path:    "/webhook/distribution",
+			payload: `{"events":[{"action":"push","target":{"repository":"acme/app"},"request":{"host":"registry.example.com:5000"}},{"action":"push","target":{"repository":"acme/app","tag":"1.0.0"},"request":{"host":"registry.example.com:5000"}}]}`,
+			header: map[string]string{
+				"X-Hub-Signature-256": sign(`{"events":[{"action":"push","target":{"repository":"acme/app"},"request":{"host":"registry.example.com:5000"}},{"action":"push","target":{"repository":"acme/app","tag":"1.0.0"},"request":{"host":"registry.example.com:5000"}}]}`),
+			},
+			status: http.StatusAccepted,
+			want:   []string{"registry.example.com:5000/acme/app"},
+		},
+		{
+			name:    "bad signature",
+			path:    "/webhook/dockerhub",
+			payload: `{"repository":{"repo_name":"acme/app"}}`,
+			header:  map[string]string{"X-Hub-Signature-256": sign("something else")},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "missing secret",
+			path:    "/webhook/dockerhub",
+			payload: `{"repository":{"repo_name":"acme/app"}}`,
+			status:  http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got recorder
+			r := &Receiver{Secret: secret, Notifier: &got, Logger: logrtesting.NullLogger{}}
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.payload))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.Handler().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("want status %d, got %d", tt.status, w.Code)
+			}
+			if !reflect.DeepEqual([]string(got), tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReceiverWithoutSecret(t *testing.T) {
+	var got recorder
+	r := &Receiver{Notifier: &got, Logger: logrtesting.NullLogger{}}
+	if err := r.ListenAndServe("127.0.0.1:0", make(chan struct{})); err != ErrNoSecret {
+		t.Errorf("want %v, got %v", ErrNoSecret, err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook/dockerhub?token=", strings.NewReader(`{"repository":{"repo_name":"acme/app"}}`))
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || len(got) != 0 {
+		t.Errorf("want status %d and no notification, got %d and %v", http.StatusUnauthorized, w.Code, got)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"manifest-updater/pkg/repository"
 
 	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"golang.org/x/sync/semaphore"
 )
 
@@ -19,35 +20,94 @@ var timeout = 20 * time.Second
 
 type UpdateLooper struct {
 	updaters      map[string]*Updater
+	firstSeen     map[string]*registry.FirstSeen
 	checkInterval time.Duration
 	logger        logr.Logger
 
 	user  string
 	token string
+	app   *repository.GitHubApp
 
-	queue <-chan *Entry
+	queue  <-chan *Entry
+	pushed chan string
 }
 
-func NewUpdateLooper(queue <-chan *Entry, c time.Duration, logger logr.Logger, user, token string) *UpdateLooper {
+func NewUpdateLooper(queue <-chan *Entry, c time.Duration, logger logr.Logger, user, token string, app *repository.GitHubApp) *UpdateLooper {
 	return &UpdateLooper{
 		updaters:      map[string]*Updater{},
+		firstSeen:     map[string]*registry.FirstSeen{},
 		checkInterval: c,
 		logger:        logger,
 		user:          user,
 		token:         token,
+		app:           app,
 		queue:         queue,
+		pushed:        make(chan string, 100),
+	}
+}
+
+// Notify runs the updaters watching repository without waiting for the
+// next tick. Notifications are dropped while the looper is busy, the
+// next tick picks them up anyway.
+func (u *UpdateLooper) Notify(repository string) {
+	select {
+	case u.pushed <- registry.NormalizeRepository(repository):
+	default:
+		u.logger.Info(fmt.Sprintf("Dropped a notification: %s", repository))
 	}
 }
 
 type Entry struct {
-	ID        string `json:"-"`
-	Deleted   bool   `json:"-"`
-	DockerHub string `json:"dockerHub"`
-	Filter    string `json:"filter,omitempty"`
-	Git       string `json:"git"`
-	Base      string `json:"base,omitempty"`
-	Head      string `json:"head,omitempty"`
-	Path      string `json:"path,omitempty"`
+	ID         string        `json:"-"`
+	Namespace  string        `json:"namespace,omitempty"`
+	Name       string        `json:"name,omitempty"`
+	Deleted    bool          `json:"-"`
+	DockerHub  string        `json:"dockerHub,omitempty"`
+	OCI        string        `json:"oci,omitempty"`
+	Insecure   bool          `json:"insecure,omitempty"`
+	CABundle   string        `json:"-"`
+	HelmURL    string        `json:"helmURL,omitempty"`
+	HelmChart  string        `json:"helmChart,omitempty"`
+	GitTags    string        `json:"gitTags,omitempty"`
+	Releases   bool          `json:"releases,omitempty"`
+	Filter     string        `json:"filter,omitempty"`
+	Include    []string      `json:"include,omitempty"`
+	Exclude    []string      `json:"exclude,omitempty"`
+	Extract    string        `json:"extract,omitempty"`
+	Policy     string        `json:"policy,omitempty"`
+	Range      string        `json:"range,omitempty"`
+	Prerelease bool          `json:"prerelease,omitempty"`
+	MinAge     time.Duration `json:"minAge,omitempty"`
+	AgeSource  string        `json:"ageSource,omitempty"`
+	// FirstSeen is shared by the Updaters built from the entries of the
+	// same ID, so that tags keep their age across reconciles.
+	FirstSeen *registry.FirstSeen `json:"-"`
+	Platforms []string            `json:"platforms,omitempty"`
+	Pin       string              `json:"pin,omitempty"`
+	Image     string              `json:"image,omitempty"`
+
+	Keychain authn.Keychain `json:"-"`
+	// Verify requires the tags to be signed with CosignPublicKey.
+	Verify          bool   `json:"verify,omitempty"`
+	CosignPublicKey []byte `json:"-"`
+	BuilderID       string `json:"builderID,omitempty"`
+
+	Git      string `json:"git"`
+	Base     string `json:"base,omitempty"`
+	Head     string `json:"head,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Mode     string `json:"mode,omitempty"`
+	Strategy string `json:"strategy,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	APIURL   string `json:"apiURL,omitempty"`
+
+	// GitUser and GitToken are the credentials of the Updater for Git and
+	// its provider, read from its credentials Secret.
+	GitUser  string `json:"-"`
+	GitToken string `json:"-"`
+
+	ValuesFiles []string `json:"valuesFiles,omitempty"`
+	ValuesKeys  []string `json:"valuesKeys,omitempty"`
 }
 
 func (u *UpdateLooper) Loop(stop <-chan struct{}) error {
@@ -60,6 +120,67 @@ func (u *UpdateLooper) Loop(stop <-chan struct{}) error {
 		rlocker = repoLocker{m: sync.Map{}}
 	)
 
+	run := func(updater *Updater) {
+		if until, limited := registry.RateLimited(updater.RegistryName); limited {
+			u.logger.Info(fmt.Sprintf("Deferred until %s due to registry rate limit: %s", until.Format(time.RFC3339), updater.RegistryName))
+			return
+		}
+
+		mux := rlocker.Load(updater.RepositoryName)
+		if mux == nil {
+			mux = &sync.Mutex{}
+			rlocker.Store(updater.RepositoryName, mux)
+		}
+
+		sem.Acquire(context.Background(), 1)
+		wg.Add(1)
+		go func() {
+			defer func() {
+				mux.Unlock()
+				sem.Release(1)
+				wg.Done()
+			}()
+
+			mux.Lock()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			errch := make(chan error, 1)
+			go func() {
+				errch <- updater.Run(ctx)
+			}()
+
+			select {
+			case <-ctx.Done():
+				u.logger.Error(ctx.Err(), "Updater")
+			case err := <-errch:
+				j, _ := json.Marshal(updater)
+				for _, r := range updater.Rejections() {
+					u.logger.Info(fmt.Sprintf("Image tag was skipped, %s: %s", r.Error(), string(j)))
+				}
+				switch {
+				case errors.Is(err, repository.ErrTagAlreadyUpToDate):
+					u.logger.Info(fmt.Sprintf("Image tag already up to date: %s", string(j)))
+				case errors.Is(err, repository.ErrPullRequestAlreadyExists):
+					u.logger.Info(fmt.Sprintf("Pull request already exists: %s", string(j)))
+				case errors.Is(err, repository.ErrTagNotReplaced):
+					u.logger.Info(fmt.Sprintf("Image tag was not replaced: %s", string(j)))
+				case errors.Is(err, registry.ErrRateLimited):
+					u.logger.Info(fmt.Sprintf("Deferred due to registry rate limit: %s", string(j)))
+				case errors.Is(err, registry.ErrNoTagsFound):
+					u.logger.Info(fmt.Sprintf("Image tag was not found: %s", string(j)))
+				case err != nil:
+					u.logger.Error(err, "Updater")
+				case updater.Mode == ModePush:
+					u.logger.Info(fmt.Sprintf("Image tag was pushed: %s", string(j)))
+				default:
+					u.logger.Info(fmt.Sprintf("Pull request was created: %s", string(j)))
+				}
+			}
+		}()
+	}
+
 	for {
 		select {
 		case entry, ok := <-u.queue:
@@ -69,10 +190,13 @@ func (u *UpdateLooper) Loop(stop <-chan struct{}) error {
 			j, _ := json.Marshal(entry)
 
 			if entry.Deleted {
-				delete(u.updaters, entry.ID)
+				u.remove(entry)
 				u.logger.Info(fmt.Sprintf("Deleted a entry: %v", string(j)))
 			} else {
-				u.updaters[entry.ID] = NewUpdater(entry, u.user, u.token)
+				if err := u.add(entry); err != nil {
+					u.logger.Error(err, fmt.Sprintf("Invalid entry: %v", string(j)))
+					continue
+				}
 				u.logger.Info(fmt.Sprintf("Added a entry: %v", string(j)))
 			}
 		case <-stop:
@@ -80,55 +204,39 @@ func (u *UpdateLooper) Loop(stop <-chan struct{}) error {
 			return nil
 		case <-ticker.C:
 			for id := range u.updaters {
-				updater := u.updaters[id]
-
-				mux := rlocker.Load(updater.RepositoryName)
-				if mux == nil {
-					mux = &sync.Mutex{}
-					rlocker.Store(updater.RepositoryName, mux)
+				run(u.updaters[id])
+			}
+		case repository := <-u.pushed:
+			for id := range u.updaters {
+				if u.updaters[id].RegistryName == repository {
+					run(u.updaters[id])
 				}
-
-				sem.Acquire(context.Background(), 1)
-				wg.Add(1)
-				go func() {
-					defer func() {
-						mux.Unlock()
-						sem.Release(1)
-						wg.Done()
-					}()
-
-					mux.Lock()
-
-					ctx, cancel := context.WithTimeout(context.Background(), timeout)
-					defer cancel()
-
-					errch := make(chan error, 1)
-					go func() {
-						errch <- updater.Run(ctx)
-					}()
-
-					select {
-					case <-ctx.Done():
-						u.logger.Error(ctx.Err(), "Updater")
-					case err := <-errch:
-						j, _ := json.Marshal(updater)
-						switch {
-						case errors.Is(err, repository.ErrTagAlreadyUpToDate):
-							u.logger.Info(fmt.Sprintf("Image tag already up to date: %s", string(j)))
-						case errors.Is(err, repository.ErrPullRequestAlreadyExists):
-							u.logger.Info(fmt.Sprintf("Pull request already exists: %s", string(j)))
-						case errors.Is(err, repository.ErrTagNotReplaced):
-							u.logger.Info(fmt.Sprintf("Image tag was not replaced: %s", string(j)))
-						case errors.Is(err, registry.ErrNoTagsFound):
-							u.logger.Info(fmt.Sprintf("Image tag was not found: %s", string(j)))
-						case err != nil:
-							u.logger.Error(err, "Updater")
-						default:
-							u.logger.Info(fmt.Sprintf("Pull request was created: %s", string(j)))
-						}
-					}
-				}()
 			}
 		}
 	}
 }
+
+// add builds the Updater of entry, replacing the previous one of the same
+// ID. The first-seen times of the tags are carried over. When entry is
+// invalid the previous Updater is stopped rather than kept running the
+// outdated spec.
+func (u *UpdateLooper) add(entry *Entry) error {
+	seen, ok := u.firstSeen[entry.ID]
+	if !ok {
+		seen = registry.NewFirstSeen()
+	}
+	entry.FirstSeen = seen
+	updater, err := NewUpdater(entry, u.user, u.token, u.app)
+	if err != nil {
+		delete(u.updaters, entry.ID)
+		return err
+	}
+	u.updaters[entry.ID] = updater
+	u.firstSeen[entry.ID] = seen
+	return nil
+}
+
+func (u *UpdateLooper) remove(entry *Entry) {
+	delete(u.updaters, entry.ID)
+	delete(u.firstSeen, entry.ID)
+}
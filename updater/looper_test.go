@@ -0,0 +1,130 @@
+package updater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"manifest-updater/pkg/registry"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestUpdateLooperKeepsFirstSeen(t *testing.T) {
+	u := NewUpdateLooper(nil, time.Minute, log.NullLogger{}, "", "", nil)
+	entry := func() *Entry {
+		return &Entry{ID: "uid", DockerHub: "acme/app", MinAge: time.Hour}
+	}
+	firstSeen := func() *registry.FirstSeen {
+		return u.updaters["uid"].Registry.(*registry.DockerHubRegistry).Filter.FirstSeen
+	}
+
+	if err := u.add(entry()); err != nil {
+		t.Fatal(err)
+	}
+	seen := firstSeen()
+	if seen == nil {
+		t.Fatal("want first-seen times, got nil")
+	}
+	if err := u.add(entry()); err != nil {
+		t.Fatal(err)
+	}
+	if firstSeen() != seen {
+		t.Error("the rebuilt Updater lost the first-seen times")
+	}
+
+	u.remove(&Entry{ID: "uid", Deleted: true})
+	if err := u.add(entry()); err != nil {
+		t.Fatal(err)
+	}
+	if firstSeen() == seen {
+		t.Error("the first-seen times of a deleted Updater were kept")
+	}
+}
+
+func TestUpdateLooperDropsInvalidEntry(t *testing.T) {
+	u := NewUpdateLooper(nil, time.Minute, log.NullLogger{}, "", "", nil)
+	if err := u.add(&Entry{ID: "uid", DockerHub: "acme/app"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.add(&Entry{ID: "uid", DockerHub: "acme/app", Filter: "("}); err == nil {
+		t.Fatal("want an error for an invalid filter")
+	}
+	if _, ok := u.updaters["uid"]; ok {
+		t.Error("the Updater of the previous spec is still running")
+	}
+}
+
+// recorder is a logr.Logger keeping the messages logged.
+type recorder struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recorder) Info(msg string, keysAndValues ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, msg)
+}
+func (r *recorder) Error(err error, msg string, keysAndValues ...interface{}) {
+	r.Info(msg + ": " + err.Error())
+}
+func (r *recorder) Enabled() bool                                       { return true }
+func (r *recorder) V(level int) logr.InfoLogger                         { return r }
+func (r *recorder) WithValues(keysAndValues ...interface{}) logr.Logger { return r }
+func (r *recorder) WithName(name string) logr.Logger                    { return r }
+
+func (r *recorder) count(prefix string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int
+	for _, m := range r.messages {
+		if strings.HasPrefix(m, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestUpdateLooperDefersRateLimitedRegistry(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	queue := make(chan *Entry, 1)
+	logger := &recorder{}
+	u := NewUpdateLooper(queue, 10*time.Millisecond, logger, "", "", nil)
+	queue <- &Entry{
+		ID:       "uid",
+		OCI:      strings.TrimPrefix(server.URL, "http://") + "/acme/app",
+		Insecure: true,
+		Git:      "https://github.com/acme/manifests.git",
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- u.Loop(stop) }()
+	time.Sleep(200 * time.Millisecond)
+	stop <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if n := logger.count("Deferred due to registry rate limit"); n != 1 {
+		t.Errorf("want the first run rate limited, got %d", n)
+	}
+	if n := logger.count("Deferred until"); n == 0 {
+		t.Error("want the next runs deferred")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("want a single request to the registry, got %d", n)
+	}
+}
@@ -2,35 +2,241 @@ package updater
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"manifest-updater/pkg/registry"
 	"manifest-updater/pkg/repository"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const (
+	// PinTag writes image:tag.
+	PinTag = "tag"
+	// PinDigest writes image@sha256:...
+	PinDigest = "digest"
+	// PinTagDigest writes image:tag@sha256:...
+	PinTagDigest = "tagDigest"
+)
+
+const (
+	// ModePullRequest pushes to the head branch and opens a pull request.
+	ModePullRequest = "pullRequest"
+	// ModePush commits to the base branch directly.
+	ModePush = "push"
+)
+
+var (
+	ErrNoImageName = errors.New("No image name to replace")
+	ErrUnknownMode = errors.New("Unknown repository mode")
+	ErrNoGitToken  = errors.New("No credentials secret for the repository provider")
+	ErrNoPublicKey = errors.New("No cosign public key to verify the tags with")
 )
 
 type Updater struct {
 	RepositoryName string                `json:"-"`
+	RegistryName   string                `json:"-"`
 	ImageName      string                `json:"-"`
+	Pin            string                `json:"pin,omitempty"`
+	Mode           string                `json:"mode,omitempty"`
 	Registry       registry.Registry     `json:"registry"`
 	Repository     repository.Repository `json:"repository"`
 }
 
-func NewUpdater(entry *Entry, user, token string) *Updater {
+// NewUpdater returns the Updater of entry. The GitHub repositories are
+// accessed as app when it is not nil, with the token of user otherwise.
+func NewUpdater(entry *Entry, user, token string, app *repository.GitHubApp) (*Updater, error) {
+	// Chart repository indexes and git tags have no images to check.
+	indexed := (entry.HelmURL != "" && !strings.HasPrefix(entry.HelmURL, "oci://")) || entry.GitTags != ""
+	if indexed && (entry.Verify || len(entry.Platforms) > 0 || (entry.MinAge > 0 && entry.AgeSource == registry.AgeSourceCreated)) {
+		return nil, registry.ErrChecksUnsupported
+	}
+	policyName := entry.Policy
+	if policyName == "" && entry.HelmURL != "" {
+		// Chart versions are semantic versions, see NewHelmRepository.
+		policyName = registry.PolicySemver
+	}
+	policy, err := registry.NewTagPolicy(policyName, entry.Range, entry.Prerelease)
+	if err != nil {
+		return nil, err
+	}
+	include := entry.Include
+	if entry.Filter != "" {
+		include = append([]string{entry.Filter}, include...)
+	}
+	if entry.Policy == registry.PolicyCreated && entry.Extract != "" {
+		return nil, registry.ErrExtractUnsupported
+	}
+	filter, err := registry.NewTagFilter(include, entry.Exclude, entry.Extract)
+	if err != nil {
+		return nil, err
+	}
+	if err := filter.SetPlatforms(entry.Platforms); err != nil {
+		return nil, err
+	}
+	if entry.Verify && len(entry.CosignPublicKey) == 0 {
+		return nil, ErrNoPublicKey
+	}
+	if entry.Verify {
+		verifier, err := registry.NewCosignVerifier(entry.CosignPublicKey, entry.BuilderID)
+		if err != nil {
+			return nil, err
+		}
+		filter.Verifier = verifier
+	}
+	if entry.MinAge > 0 {
+		if err := filter.SetMinAge(entry.MinAge, entry.AgeSource); err != nil {
+			return nil, err
+		}
+		filter.FirstSeen = entry.FirstSeen
+	}
+	var reg registry.Registry = registry.NewDockerHubRegistry(
+		entry.DockerHub,
+		filter,
+		policy,
+		entry.Keychain,
+	)
+	if entry.OCI != "" {
+		reg, err = registry.NewOCIRegistry(
+			entry.OCI,
+			filter,
+			policy,
+			entry.Insecure,
+			[]byte(entry.CABundle),
+			entry.Keychain,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	registryName := entry.DockerHub
+	if entry.OCI != "" {
+		registryName = entry.OCI
+	}
+	replacer, err := repository.NewReplacer(entry.Strategy, repository.ReplacerOptions{
+		ValuesFiles: entry.ValuesFiles,
+		ValuesKeys:  entry.ValuesKeys,
+		Policy:      entry.Namespace + ":" + entry.Name,
+		Image:       entry.Image,
+	})
+	if err != nil {
+		return nil, err
+	}
+	imageName := registryName
+	if entry.HelmURL != "" {
+		reg, err = registry.NewHelmRepository(
+			entry.HelmURL,
+			entry.HelmChart,
+			filter,
+			policy,
+			entry.Insecure,
+			[]byte(entry.CABundle),
+			entry.Keychain,
+		)
+		if err != nil {
+			return nil, err
+		}
+		registryName = strings.TrimSuffix(strings.TrimPrefix(entry.HelmURL, "oci://"), "/") + "/" + entry.HelmChart
+		imageName = entry.HelmChart
+		replacer = repository.ChartReplacer{URL: entry.HelmURL}
+	}
+	if entry.GitTags != "" {
+		endpoint, err := transport.NewEndpoint(entry.GitTags)
+		if err != nil {
+			return nil, err
+		}
+		// Only hand the GitHub token to GitHub.
+		var githubToken string
+		if endpoint.Host == "github.com" {
+			githubToken = token
+		}
+		if entry.Releases {
+			reg = registry.NewGitHubReleases(entry.GitTags, filter, policy, entry.Prerelease, githubToken)
+		} else {
+			var auth transport.AuthMethod
+			if endpoint.Protocol == "https" && githubToken != "" {
+				auth = &http.BasicAuth{Username: user, Password: githubToken}
+			}
+			reg = registry.NewGitRepository(entry.GitTags, filter, policy, auth)
+		}
+		registryName = endpoint.Host + "/" + strings.TrimSuffix(strings.Trim(endpoint.Path, "/"), ".git")
+		imageName = registryName
+		replacer = repository.GitRefReplacer{}
+	}
+	if entry.Image != "" {
+		imageName = entry.Image
+	}
+	if imageName == "" {
+		return nil, ErrNoImageName
+	}
+	repo, err := newRepository(entry, user, token, app, replacer)
+	if err != nil {
+		return nil, err
+	}
 	return &Updater{
-		Registry: registry.NewDockerHubRegistry(
-			entry.DockerHub,
-			entry.Filter,
-		),
-		Repository: repository.NewGitHubRepository(
-			entry.Git,
-			entry.Base,
-			entry.Head,
-			entry.Path,
-			repository.GithubAuth{
-				User:  user,
-				Token: token,
-			},
-		),
+		RegistryName: registry.NormalizeRepository(registryName),
+		ImageName:    imageName,
+		Pin:          entry.Pin,
+		Mode:         entry.Mode,
+		Registry:     reg,
+		Repository:   repo,
+	}, nil
+}
+
+// newRepository returns the Repository of the hosting provider of entry.
+// user, token and app are the credentials of the operator, which are only
+// handed to GitHub. The other providers need the credentials of entry.
+func newRepository(entry *Entry, user, token string, app *repository.GitHubApp, replacer repository.Replacer) (repository.Repository, error) {
+	switch entry.Mode {
+	case "", ModePullRequest, ModePush:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMode, entry.Mode)
+	}
+	if entry.Provider != "" && entry.Provider != repository.ProviderGitHub && entry.GitToken == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoGitToken, entry.Provider)
 	}
+	git := repository.NewGitSettings(entry.Git, entry.Base, entry.Head, entry.Path)
+	git.Replacer = replacer
+	git.Push = entry.Mode == ModePush
+
+	switch entry.Provider {
+	case "", repository.ProviderGitHub:
+		auth := repository.GithubAuth{
+			User:  user,
+			Token: token,
+			App:   app,
+		}
+		if entry.GitToken != "" {
+			auth = repository.GithubAuth{
+				User:  entry.GitUser,
+				Token: entry.GitToken,
+			}
+		}
+		return repository.NewGitHubRepository(git, auth), nil
+	case repository.ProviderGitLab:
+		return repository.NewGitLabRepository(git, entry.APIURL, repository.GitLabAuth{Token: entry.GitToken}), nil
+	case repository.ProviderGitea:
+		return repository.NewGiteaRepository(git, entry.APIURL, repository.GiteaAuth{Token: entry.GitToken}), nil
+	case repository.ProviderBitbucketServer:
+		return repository.NewBitbucketServerRepository(git, entry.APIURL, repository.BitbucketAuth{
+			User:  entry.GitUser,
+			Token: entry.GitToken,
+		}), nil
+	case repository.ProviderAzureDevOps:
+		return repository.NewAzureDevOpsRepository(git, entry.APIURL, repository.AzureAuth{Token: entry.GitToken}), nil
+	}
+	return nil, fmt.Errorf("%w: %s", repository.ErrUnknownProvider, entry.Provider)
+}
+
+// Rejections returns the tags the registry held back during the latest Run.
+func (u *Updater) Rejections() []registry.Rejection {
+	if r, ok := u.Registry.(registry.Rejector); ok {
+		return r.Rejections()
+	}
+	return nil
 }
 
 func (u *Updater) Run(ctx context.Context) error {
@@ -38,8 +244,25 @@ func (u *Updater) Run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	if err := u.Repository.PushReplaceTagCommit(ctx, u.ImageName, tag); err != nil {
+	var digest string
+	if u.Pin == PinDigest || u.Pin == PinTagDigest {
+		resolver, ok := u.Registry.(registry.DigestResolver)
+		if !ok {
+			return registry.ErrDigestUnsupported
+		}
+		digest, err = resolver.ResolveDigest(ctx, tag)
+		if err != nil {
+			return err
+		}
+		if u.Pin == PinDigest {
+			tag = ""
+		}
+	}
+	if err := u.Repository.PushReplaceTagCommit(ctx, u.ImageName, tag, digest); err != nil {
 		return err
 	}
+	if u.Mode == ModePush {
+		return nil
+	}
 	return u.Repository.CreatePullRequest(ctx)
 }
@@ -0,0 +1,302 @@
+package updater
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"manifest-updater/pkg/registry"
+	"manifest-updater/pkg/repository"
+)
+
+func TestNewUpdaterImageName(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *Entry
+		want  string
+	}{
+		{
+			name:  "docker hub",
+			entry: &Entry{DockerHub: "index.docker.io/acme/app"},
+			want:  "index.docker.io/acme/app",
+		},
+		{
+			name:  "oci",
+			entry: &Entry{OCI: "harbor.example.com/acme/app"},
+			want:  "harbor.example.com/acme/app",
+		},
+		{
+			name:  "mirror",
+			entry: &Entry{DockerHub: "index.docker.io/acme/app", Image: "mirror.example.com/acme/app"},
+			want:  "mirror.example.com/acme/app",
+		},
+		{
+			name:  "helm chart",
+			entry: &Entry{HelmURL: "https://charts.example.com", HelmChart: "app"},
+			want:  "app",
+		},
+		{
+			name:  "git tags",
+			entry: &Entry{GitTags: "https://github.com/acme/crds.git"},
+			want:  "github.com/acme/crds",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := NewUpdater(tt.entry, "", "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.ImageName != tt.want {
+				t.Errorf("want %q, got %q", tt.want, u.ImageName)
+			}
+		})
+	}
+
+	if _, err := NewUpdater(&Entry{}, "", "", nil); err != ErrNoImageName {
+		t.Errorf("want %v, got %v", ErrNoImageName, err)
+	}
+}
+
+type fakeRegistry struct{ tag string }
+
+func (f fakeRegistry) FetchLatestTag(ctx context.Context) (string, error) { return f.tag, nil }
+
+type fakeRepository struct {
+	pushed string
+	digest string
+	pulls  int
+}
+
+func (f *fakeRepository) PushReplaceTagCommit(ctx context.Context, image, tag, digest string) error {
+	f.pushed = image + ":" + tag
+	f.digest = digest
+	return nil
+}
+
+func (f *fakeRepository) CreatePullRequest(ctx context.Context) error {
+	f.pulls++
+	return nil
+}
+
+func TestUpdaterRunMode(t *testing.T) {
+	for mode, pulls := range map[string]int{ModePullRequest: 1, ModePush: 0} {
+		t.Run(mode, func(t *testing.T) {
+			repo := &fakeRepository{}
+			u := &Updater{ImageName: "acme/app", Mode: mode, Registry: fakeRegistry{tag: "v2"}, Repository: repo}
+			if err := u.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if repo.pushed != "acme/app:v2" || repo.pulls != pulls {
+				t.Errorf("want acme/app:v2 and %d pull requests, got %s and %d", pulls, repo.pushed, repo.pulls)
+			}
+		})
+	}
+
+	for mode, push := range map[string]bool{ModePullRequest: false, ModePush: true} {
+		u, err := NewUpdater(&Entry{DockerHub: "acme/app", Git: "https://github.com/acme/manifests.git", Base: "main", Mode: mode}, "", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo := u.Repository.(*repository.GitHubRepository)
+		if repo.Push != push || repo.Head != repository.DefaultHead {
+			t.Errorf("%s: want push %v to %s, got %v to %s", mode, push, repository.DefaultHead, repo.Push, repo.Head)
+		}
+	}
+
+	if _, err := NewUpdater(&Entry{DockerHub: "acme/app", Mode: "merge"}, "", "", nil); !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("want %v, got %v", ErrUnknownMode, err)
+	}
+}
+
+func TestUpdaterRunPin(t *testing.T) {
+	server := httptest.NewServer(ggcrregistry.New(ggcrregistry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer server.Close()
+
+	image := strings.TrimPrefix(server.URL, "http://") + "/acme/app"
+	reg, err := registry.NewOCIRegistry(image, nil, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewTag(image + ":1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The digest of 1.0.0 moves between the rounds, as when a tag is
+	// rebuilt in place.
+	for i := 0; i < 2; i++ {
+		img, err := random.Image(64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			pin    string
+			tag    string
+			digest string
+		}{
+			{PinTag, "1.0.0", ""},
+			{PinDigest, "", digest.String()},
+			{PinTagDigest, "1.0.0", digest.String()},
+		}
+		for _, tt := range tests {
+			repo := &fakeRepository{}
+			u := &Updater{ImageName: "acme/app", Pin: tt.pin, Registry: reg, Repository: repo}
+			if err := u.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if repo.pushed != "acme/app:"+tt.tag || repo.digest != tt.digest {
+				t.Errorf("%s: want acme/app:%s and %q, got %s and %q", tt.pin, tt.tag, tt.digest, repo.pushed, repo.digest)
+			}
+		}
+	}
+
+	u := &Updater{ImageName: "acme/app", Pin: PinDigest, Registry: fakeRegistry{tag: "v2"}, Repository: &fakeRepository{}}
+	if err := u.Run(context.Background()); err != registry.ErrDigestUnsupported {
+		t.Errorf("want %v, got %v", registry.ErrDigestUnsupported, err)
+	}
+}
+
+func TestNewUpdaterHelmPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("entries:\n  app:\n  - version: 9.0.0\n  - version: 10.0.0\n"))
+	}))
+	defer server.Close()
+
+	for policy, want := range map[string]string{"": "10.0.0", registry.PolicyAlphabetical: "9.0.0"} {
+		u, err := NewUpdater(&Entry{HelmURL: server.URL, HelmChart: "app", Policy: policy}, "", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tag, err := u.Registry.FetchLatestTag(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag != want {
+			t.Errorf("policy %q: want %s, got %s", policy, want, tag)
+		}
+	}
+}
+
+func TestNewUpdaterUnsupportedChecks(t *testing.T) {
+	tests := []*Entry{
+		{HelmURL: "https://charts.example.com", HelmChart: "app", Platforms: []string{"linux/amd64"}},
+		{GitTags: "https://github.com/acme/crds", Verify: true, CosignPublicKey: []byte("key")},
+		{GitTags: "https://github.com/acme/crds", Releases: true, MinAge: time.Hour, AgeSource: registry.AgeSourceCreated},
+	}
+	for _, entry := range tests {
+		if _, err := NewUpdater(entry, "", "", nil); err != registry.ErrChecksUnsupported {
+			t.Errorf("want %v, got %v", registry.ErrChecksUnsupported, err)
+		}
+	}
+
+	entry := &Entry{HelmURL: "oci://ghcr.io/acme/charts", HelmChart: "app", Platforms: []string{"linux/amd64"}}
+	if _, err := NewUpdater(entry, "", "", nil); err != nil {
+		t.Errorf("want OCI charts checked, got %v", err)
+	}
+	entry = &Entry{GitTags: "https://github.com/acme/crds", MinAge: time.Hour}
+	if _, err := NewUpdater(entry, "", "", nil); err != nil {
+		t.Errorf("want git tags aged since first seen, got %v", err)
+	}
+}
+
+func TestNewUpdaterVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	if _, err := NewUpdater(&Entry{DockerHub: "acme/app", Verify: true}, "", "", nil); err != ErrNoPublicKey {
+		t.Errorf("want %v, got %v", ErrNoPublicKey, err)
+	}
+	u, err := NewUpdater(&Entry{DockerHub: "acme/app", Verify: true, CosignPublicKey: publicKey}, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Registry.(*registry.DockerHubRegistry).Filter.Verifier == nil {
+		t.Error("want the tags verified")
+	}
+}
+
+func TestNewUpdaterGitHubToken(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://github.com/acme/crds.git", "token"},
+		{"git@github.com:acme/crds.git", "token"},
+		{"https://ghe.example.com/acme/crds.git", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := NewUpdater(&Entry{GitTags: tt.url, Releases: true}, "user", "token", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.Registry.(*registry.GitHubReleases).Token; got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewUpdaterProviderCredentials(t *testing.T) {
+	entry := &Entry{DockerHub: "acme/app", Git: "https://gitlab.example.com/acme/manifests.git", Provider: repository.ProviderGitLab}
+	if _, err := NewUpdater(entry, "user", "operator", nil); !errors.Is(err, ErrNoGitToken) {
+		t.Errorf("want %v, got %v", ErrNoGitToken, err)
+	}
+
+	entry.GitToken = "updater"
+	u, err := NewUpdater(entry, "user", "operator", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Repository.(*repository.GitLabRepository).Auth.Token; got != "updater" {
+		t.Errorf("want %q, got %q", "updater", got)
+	}
+
+	entry = &Entry{DockerHub: "acme/app", Git: "https://github.com/acme/manifests.git"}
+	u, err = NewUpdater(entry, "user", "operator", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Repository.(*repository.GitHubRepository).Auth.Token; got != "operator" {
+		t.Errorf("want %q, got %q", "operator", got)
+	}
+	entry.GitUser, entry.GitToken = "bot", "updater"
+	u, err = NewUpdater(entry, "user", "operator", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Repository.(*repository.GitHubRepository).Auth; got.User != "bot" || got.Token != "updater" {
+		t.Errorf("want bot/updater, got %s/%s", got.User, got.Token)
+	}
+}